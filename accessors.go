@@ -0,0 +1,506 @@
+package terminfo
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+)
+
+// PrintCaps are the media copy (printer) capabilities of a terminal.
+type PrintCaps struct {
+	// PrintScreen is the print_screen (mc0) cap, that prints the contents of
+	// the screen.
+	PrintScreen []byte
+	// PrtrOn is the prtr_on (mc5) cap, that turns on the printer.
+	PrtrOn []byte
+	// PrtrOff is the prtr_off (mc4) cap, that turns off the printer.
+	PrtrOff []byte
+	// PrtrNon is the prtr_non (mc5p) cap, that turns on the printer for the
+	// next #1 bytes.
+	PrtrNon []byte
+	// PrtrSilent is the prtr_silent (mc5i) cap, indicating the printer will
+	// not echo on the screen.
+	PrtrSilent bool
+}
+
+// PrintCaps returns the media copy (printer) capabilities.
+func (ti *Terminfo) PrintCaps() PrintCaps {
+	return PrintCaps{
+		PrintScreen: ti.Strings[PrintScreen],
+		PrtrOn:      ti.Strings[PrtrOn],
+		PrtrOff:     ti.Strings[PrtrOff],
+		PrtrNon:     ti.Strings[PrtrNon],
+		PrtrSilent:  ti.Bools[PrtrSilent],
+	}
+}
+
+// StatusLine returns the sequence that writes text to the terminal's status
+// line, moving to the first column of the status line with to_status_line
+// (tsl) and returning with from_status_line (fsl). Returns ErrNoStatusLine
+// when the terminal does not have a status line (hs).
+func (ti *Terminfo) StatusLine(text string) ([]byte, error) {
+	tsl := ti.Strings[ToStatusLine]
+	if !ti.Bools[HasStatusLine] || tsl == nil {
+		return nil, ErrNoStatusLine
+	}
+	// tsl takes the column as its only parameter
+	buf := []byte(Printf(tsl, 0))
+	buf = append(buf, text...)
+	return append(buf, ti.Strings[FromStatusLine]...), nil
+}
+
+// Clear modes for ClearLine.
+const (
+	// ClearModeEndOfLine clears from the cursor to the end of the line.
+	ClearModeEndOfLine = iota
+	// ClearModeBeginningOfLine clears from the beginning of the line to the
+	// cursor.
+	ClearModeBeginningOfLine
+	// ClearModeEndOfScreen clears from the cursor to the end of the screen.
+	ClearModeEndOfScreen
+)
+
+// ClearLine returns the clr_eol (el), clr_bol (el1) or clr_eos (ed) cap for
+// the clear mode, or nil if the mode is not supported.
+func (ti *Terminfo) ClearLine(mode int) []byte {
+	switch mode {
+	case ClearModeEndOfLine:
+		return ti.Strings[ClrEol]
+	case ClearModeBeginningOfLine:
+		return ti.Strings[ClrBol]
+	case ClearModeEndOfScreen:
+		return ti.Strings[ClrEos]
+	}
+	return nil
+}
+
+// ClearToEnd returns the sequence that clears from the cursor to the end of
+// the line (el).
+func (ti *Terminfo) ClearToEnd() []byte {
+	return ti.ClearLine(ClearModeEndOfLine)
+}
+
+// BackColorErase reports whether the terminal erases with the current
+// background color (bce). When false, renderers must write spaces to paint a
+// background color instead of using the erase caps.
+func (ti *Terminfo) BackColorErase() bool {
+	return ti.Bools[BackColorErase]
+}
+
+// BackspaceWraps reports whether cub1 at the leftmost column wraps to the last
+// column of the previous line (bw). When false, moving left at column 0 is
+// undefined (most terminals stay put), so renderers must not rely on it to
+// reach the end of the previous line.
+func (ti *Terminfo) BackspaceWraps() bool {
+	return ti.Bools[AutoLeftMargin]
+}
+
+// InitStrings returns the terminal's initialization strings, in the order
+// described in terminfo(5): the init_prog (iprog) path, init_1string (is1),
+// init_2string (is2), the contents of init_file (if), and init_3string
+// (is3). Caps that are not defined, or an init_file that cannot be read, are
+// omitted.
+func (ti *Terminfo) InitStrings() [][]byte {
+	var z [][]byte
+	for _, i := range []int{InitProg, Init1string, Init2string, InitFile, Init3string} {
+		s := ti.Strings[i]
+		if s == nil {
+			continue
+		}
+		if i == InitFile {
+			var err error
+			if s, err = ioutil.ReadFile(string(s)); err != nil {
+				continue
+			}
+		}
+		z = append(z, s)
+	}
+	return z
+}
+
+// Sixel reports whether the terminal advertises sixel graphics support, via
+// the Sxl extended bool cap (as used by tmux) or a sixel extended bool cap.
+func (ti *Terminfo) Sixel() bool {
+	for _, name := range []string{"Sxl", "sixel"} {
+		if v, ok := ti.ExtBool(name); ok && v {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtendedKeys returns the extended key caps (such as kUP3, kDN5 or kLFT6 for
+// modified arrow keys), keyed by their name.
+func (ti *Terminfo) ExtendedKeys() map[string][]byte {
+	m := make(map[string][]byte)
+	for k, v := range ti.ExtStrings {
+		if name := ti.ExtStringName(k); strings.HasPrefix(name, "k") {
+			m[name] = v
+		}
+	}
+	return m
+}
+
+// CursorPositionReport returns the user7 (u7) cap, used to query the cursor
+// position, and the user6 (u6) cap, the scanf style format of the terminal's
+// response (for example \E[%i%d;%dR). Reports false if either is not defined.
+func (ti *Terminfo) CursorPositionReport() (query, format []byte, ok bool) {
+	query, format = ti.Strings[User7], ti.Strings[User6]
+	if query == nil || format == nil {
+		return nil, nil, false
+	}
+	return query, format, true
+}
+
+// SaveCursor returns the save_cursor (sc) cap, or nil when not defined (in
+// which case callers should fall back to cursor addressing).
+func (ti *Terminfo) SaveCursor() []byte {
+	return ti.Strings[SaveCursor]
+}
+
+// RestoreCursor returns the restore_cursor (rc) cap, or nil when not defined.
+func (ti *Terminfo) RestoreCursor() []byte {
+	return ti.Strings[RestoreCursor]
+}
+
+// Columns returns the columns (cols) cap, reporting whether it is defined.
+//
+// Note that many terminals (such as vt100) define a static cols#80, so
+// callers should prefer the window size reported by the OS when available.
+func (ti *Terminfo) Columns() (int, bool) {
+	return ti.num(Columns)
+}
+
+// Lines returns the lines (lines) cap, reporting whether it is defined. See
+// Columns.
+func (ti *Terminfo) Lines() (int, bool) {
+	return ti.num(Lines)
+}
+
+// num returns the num cap i, reporting whether it is defined.
+func (ti *Terminfo) num(i int) (int, bool) {
+	n, ok := ti.Nums[i]
+	if !ok || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// OrigColors returns the orig_colors (oc) cap, that resets all color
+// definitions to the terminal's defaults, or nil when not defined.
+func (ti *Terminfo) OrigColors() []byte {
+	return ti.Strings[OrigColors]
+}
+
+// OrigPair returns the orig_pair (op) cap, that resets the foreground and
+// background colors to the terminal's defaults, or nil when not defined.
+//
+// Renderers should use this (and not exit_attribute_mode) to reset colors.
+func (ti *Terminfo) OrigPair() []byte {
+	return ti.Strings[OrigPair]
+}
+
+// SetColorPair returns the set_color_pair (scp) cap evaluated with pair, that
+// sets the colors to the curses style color pair, reporting whether the
+// terminal defines the cap and pair is a valid pair (less than max_pairs,
+// when defined). Most terminals set colors with setaf and setab instead.
+func (ti *Terminfo) SetColorPair(pair int) ([]byte, bool) {
+	z := ti.Strings[SetColorPair]
+	if z == nil || pair < 0 {
+		return nil, false
+	}
+	if n, ok := ti.num(MaxPairs); ok && pair >= n {
+		return nil, false
+	}
+	return []byte(Printf(z, pair)), true
+}
+
+// Bold returns the enter_bold_mode (bold) cap, and exit_attribute_mode (sgr0)
+// as its exit sequence, as terminfo does not define a cap that only ends bold
+// mode. Returns nil when the terminal does not support bold mode.
+func (ti *Terminfo) Bold() (enter, exit []byte) {
+	return ti.attr(EnterBoldMode, ExitAttributeMode)
+}
+
+// Dim returns the enter_dim_mode (dim) cap, and exit_attribute_mode (sgr0)
+// as its exit sequence.
+func (ti *Terminfo) Dim() (enter, exit []byte) {
+	return ti.attr(EnterDimMode, ExitAttributeMode)
+}
+
+// Blink returns the enter_blink_mode (blink) cap, and exit_attribute_mode
+// (sgr0) as its exit sequence.
+func (ti *Terminfo) Blink() (enter, exit []byte) {
+	return ti.attr(EnterBlinkMode, ExitAttributeMode)
+}
+
+// Reverse returns the enter_reverse_mode (rev) cap, and exit_attribute_mode
+// (sgr0) as its exit sequence.
+func (ti *Terminfo) Reverse() (enter, exit []byte) {
+	return ti.attr(EnterReverseMode, ExitAttributeMode)
+}
+
+// Underline returns the enter_underline_mode (smul) and exit_underline_mode
+// (rmul) caps.
+func (ti *Terminfo) Underline() (enter, exit []byte) {
+	return ti.attr(EnterUnderlineMode, ExitUnderlineMode)
+}
+
+// Italic returns the enter_italics_mode (sitm) and exit_italics_mode (ritm)
+// caps.
+func (ti *Terminfo) Italic() (enter, exit []byte) {
+	return ti.attr(EnterItalicsMode, ExitItalicsMode)
+}
+
+// Standout returns the enter_standout_mode (smso) and exit_standout_mode
+// (rmso) caps.
+func (ti *Terminfo) Standout() (enter, exit []byte) {
+	return ti.attr(EnterStandoutMode, ExitStandoutMode)
+}
+
+// attr returns the enter and exit string caps of an attribute, or nil for
+// both when the enter cap is not defined.
+func (ti *Terminfo) attr(enter, exit int) ([]byte, []byte) {
+	if ti.Strings[enter] == nil {
+		return nil, nil
+	}
+	return ti.Strings[enter], ti.Strings[exit]
+}
+
+// TranslateNewlines returns p with each newline (\n or \r\n) replaced with
+// the terminal's newline (nel) cap, or with carriage_return (cr) followed by
+// scroll_forward (ind) when nel is not defined, as is needed when writing to
+// a terminal in raw mode.
+//
+// The eat_newline_glitch (xenl) cap is not taken into account, as that needs
+// the cursor column, which cannot be tracked from p alone (p may start
+// mid-line, and contain escape sequences and multi-byte or wide chars). With
+// auto_right_margin (am) and without xenl, a line exactly filling the screen
+// width wraps before the newline is written, leaving an empty line; callers
+// that track the column should omit the newline in that case.
+func (ti *Terminfo) TranslateNewlines(p []byte) []byte {
+	nl := ti.Strings[Newline]
+	if nl == nil {
+		cr, lf := ti.Strings[CarriageReturn], ti.Strings[ScrollForward]
+		if cr == nil {
+			cr = []byte("\r")
+		}
+		if lf == nil {
+			lf = []byte("\n")
+		}
+		nl = append(append(make([]byte, 0, len(cr)+len(lf)), cr...), lf...)
+	}
+	buf := make([]byte, 0, len(p))
+	for {
+		i := bytes.IndexByte(p, '\n')
+		if i == -1 {
+			return append(buf, p...)
+		}
+		j := i
+		if i > 0 && p[i-1] == '\r' {
+			j--
+		}
+		buf = append(append(buf, p[:j]...), nl...)
+		p = p[i+1:]
+	}
+}
+
+// SoftLabelCaps are the soft label key capabilities of a terminal.
+type SoftLabelCaps struct {
+	// NumLabels is the num_labels (nlab) cap, the number of soft labels, or
+	// -1 when not defined.
+	NumLabels int
+	// LabelHeight is the label_height (lh) cap, the number of rows in each
+	// label, or -1 when not defined.
+	LabelHeight int
+	// LabelWidth is the label_width (lw) cap, the number of columns in each
+	// label, or -1 when not defined.
+	LabelWidth int
+	// Labels are the lab_f0 (lf0) through lab_f10 (lf10) caps, the labels on
+	// the function keys f0 through f10, when not f0 through f10.
+	Labels [11][]byte
+	// PlabNorm is the plab_norm (pln) cap, that programs label #1 to show
+	// string #2.
+	PlabNorm []byte
+	// LabelOn is the label_on (smln) cap, that turns on the soft labels.
+	LabelOn []byte
+	// LabelOff is the label_off (rmln) cap, that turns off the soft labels.
+	LabelOff []byte
+}
+
+// SoftLabels returns the soft label key capabilities.
+func (ti *Terminfo) SoftLabels() SoftLabelCaps {
+	c := SoftLabelCaps{
+		NumLabels:   ti.Num(NumLabels),
+		LabelHeight: ti.Num(LabelHeight),
+		LabelWidth:  ti.Num(LabelWidth),
+		PlabNorm:    ti.Strings[PlabNorm],
+		LabelOn:     ti.Strings[LabelOn],
+		LabelOff:    ti.Strings[LabelOff],
+	}
+	for i, cap := range []int{LabF0, LabF1, LabF2, LabF3, LabF4, LabF5, LabF6, LabF7, LabF8, LabF9, LabF10} {
+		c.Labels[i] = ti.Strings[cap]
+	}
+	return c
+}
+
+// FlowControl returns the xon_xoff (xon) cap, reporting whether the terminal
+// uses xon/xoff handshaking, and the enter_xon_mode (smxon) and exit_xon_mode
+// (rmxon) caps, that turn xon/xoff handshaking on and off.
+//
+// When xon is set, padding is not needed (see Terminal), and the xon_character
+// (xonc) and xoff_character (xoffc) caps define the handshake characters.
+func (ti *Terminfo) FlowControl() (xon bool, smxon, rmxon []byte) {
+	return ti.Bools[XonXoff], ti.Strings[EnterXonMode], ti.Strings[ExitXonMode]
+}
+
+// InsertMode returns the enter_insert_mode (smir) and exit_insert_mode (rmir)
+// caps, that turn insert mode on and off, or nil when not defined, in which
+// case insert_character (ich1) or parm_ich (ich) can be used instead. The
+// cursor can only be moved while in insert mode when move_insert_mode (mir)
+// is set.
+func (ti *Terminfo) InsertMode() (enter, exit []byte) {
+	return ti.Strings[EnterInsertMode], ti.Strings[ExitInsertMode]
+}
+
+// DeleteMode returns the enter_delete_mode (smdc) and exit_delete_mode (rmdc)
+// caps, that turn delete mode on and off, or nil when not defined. Terminals
+// without a delete mode use delete_character (dch1) or parm_dch (dch)
+// directly.
+func (ti *Terminfo) DeleteMode() (enter, exit []byte) {
+	return ti.Strings[EnterDeleteMode], ti.Strings[ExitDeleteMode]
+}
+
+// StringCapsContaining returns the string caps whose values contain needle,
+// in cap order.
+func (ti *Terminfo) StringCapsContaining(needle []byte) []int {
+	var z []int
+	for i := 0; i < CapCountString; i++ {
+		if v := ti.Strings[i]; v != nil && bytes.Contains(v, needle) {
+			z = append(z, i)
+		}
+	}
+	return z
+}
+
+// EnableMouse returns the sequences that enable and disable mouse reporting,
+// by evaluating the extended XM cap with 1 and 0. Reports false when XM is not
+// defined.
+//
+// The related extended xm cap is the format of the terminal's mouse reports,
+// and not used here.
+func (ti *Terminfo) EnableMouse() ([]byte, []byte, bool) {
+	xm, ok := ti.ExtString("XM")
+	if !ok || xm == nil {
+		return nil, nil, false
+	}
+	return []byte(Printf(xm, 1)), []byte(Printf(xm, 0)), true
+}
+
+// ACSValid reports whether the acs_chars (acsc) cap is defined and made up of
+// complete (vt100 char, terminal char) pairs. Some entries have a trailing
+// unpaired char, and their line drawing mapping may not be trustworthy.
+func (ti *Terminfo) ACSValid() bool {
+	z := ti.Strings[AcsChars]
+	return z != nil && len(z)%2 == 0
+}
+
+// EraseToEndOfDisplay returns the sequence that clears from the cursor to the
+// end of the screen, preferring the clr_eos (ed) cap. The cursor is on the
+// 0-based row.
+//
+// When ed is not defined, a fallback is composed that saves the cursor (sc),
+// clears the line (el), clears each of the following lines (lines - row - 1)
+// by moving down (cud1) and clearing, and then restores the cursor (rc).
+// Returns nil if any of these caps are not defined, or when cud1 is a newline
+// (as it scrolls the screen when on the last line).
+func (ti *Terminfo) EraseToEndOfDisplay(row int) []byte {
+	if ed := ti.Strings[ClrEos]; ed != nil {
+		return ed
+	}
+	sc, rc, el, cud1 := ti.Strings[SaveCursor], ti.Strings[RestoreCursor], ti.Strings[ClrEol], ti.Strings[CursorDown]
+	lines, ok := ti.Lines()
+	if sc == nil || rc == nil || el == nil || cud1 == nil || bytes.Equal(cud1, []byte("\n")) || !ok {
+		return nil
+	}
+	if row < 0 {
+		row = 0
+	}
+	buf := append(append([]byte{}, sc...), el...)
+	for i := row + 1; i < lines; i++ {
+		buf = append(append(buf, cud1...), el...)
+	}
+	return append(buf, rc...)
+}
+
+// UsesACS returns the string caps (other than smacs and rmacs themselves)
+// whose values contain the enter_alt_charset_mode (smacs) or
+// exit_alt_charset_mode (rmacs) sequences, in cap order. For example, sgr0
+// commonly includes rmacs.
+func (ti *Terminfo) UsesACS() []int {
+	smacs, rmacs := ti.Strings[EnterAltCharsetMode], ti.Strings[ExitAltCharsetMode]
+	var z []int
+	for i := 0; i < CapCountString; i++ {
+		v := ti.Strings[i]
+		if v == nil || i == EnterAltCharsetMode || i == ExitAltCharsetMode {
+			continue
+		}
+		if len(smacs) != 0 && bytes.Contains(v, smacs) || len(rmacs) != 0 && bytes.Contains(v, rmacs) {
+			z = append(z, i)
+		}
+	}
+	return z
+}
+
+// Underline styles for UnderlineStyle.
+const (
+	UnderlineNone = iota
+	UnderlineSingle
+	UnderlineDouble
+	UnderlineCurly
+	UnderlineDotted
+	UnderlineDashed
+)
+
+// UnderlineStyle returns the sequence that sets the underline style, by
+// evaluating the extended Smulx cap with the style. Reports false when Smulx
+// is not defined or the style is not one of the underline styles.
+func (ti *Terminfo) UnderlineStyle(style int) ([]byte, bool) {
+	smulx, ok := ti.ExtString("Smulx")
+	if !ok || smulx == nil || style < UnderlineNone || style > UnderlineDashed {
+		return nil, false
+	}
+	return []byte(Printf(smulx, style)), true
+}
+
+// FocusEvents returns the extended fe and fd caps, that enable and disable
+// the reporting of focus in and focus out events. Reports false if either is
+// not defined.
+func (ti *Terminfo) FocusEvents() (enable, disable []byte, ok bool) {
+	fe, feOK := ti.ExtString("fe")
+	fd, fdOK := ti.ExtString("fd")
+	if !feOK || !fdOK || fe == nil || fd == nil {
+		return nil, nil, false
+	}
+	return fe, fd, true
+}
+
+// ColumnAddress returns the column_address (hpa) cap evaluated with col, that
+// moves the cursor to the absolute column col on the current row, or nil when
+// not defined. The leftmost column is 0.
+func (ti *Terminfo) ColumnAddress(col int) []byte {
+	if ti.Strings[ColumnAddress] == nil {
+		return nil
+	}
+	return []byte(ti.Printf(ColumnAddress, col))
+}
+
+// RowAddress returns the row_address (vpa) cap evaluated with row, that moves
+// the cursor to the absolute row row in the current column, or nil when not
+// defined. The top row is 0.
+func (ti *Terminfo) RowAddress(row int) []byte {
+	if ti.Strings[RowAddress] == nil {
+		return nil
+	}
+	return []byte(ti.Printf(RowAddress, row))
+}
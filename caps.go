@@ -1,5 +1,34 @@
 package terminfo
 
+import (
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// CapFamily is a capability family (bool, num, or string).
+type CapFamily uint
+
+// CapFamily values.
+const (
+	CapFamilyBool CapFamily = iota
+	CapFamilyNum
+	CapFamilyString
+)
+
+// String satisfies the Stringer interface.
+func (f CapFamily) String() string {
+	switch f {
+	case CapFamilyBool:
+		return "bool"
+	case CapFamilyNum:
+		return "num"
+	case CapFamilyString:
+		return "string"
+	}
+	return "unknown"
+}
+
 // BoolCapName returns the bool capability name.
 func BoolCapName(i int) string {
 	return boolCapNames[2*i]
@@ -29,3 +58,110 @@ func StringCapName(i int) string {
 func StringCapNameShort(i int) string {
 	return stringCapNames[2*i+1]
 }
+
+// IsObsolete determines if the cap i of the family is obsolete.
+//
+// ncurses keeps the obsolete termcap capabilities (the ones with an OT
+// prefixed short name, such as OTbs) and the legacy memory_lock,
+// memory_unlock and box_chars_1 capabilities at the end of each cap table,
+// after the standard capabilities.
+func IsObsolete(family CapFamily, i int) bool {
+	switch family {
+	case CapFamilyBool:
+		return i >= BackspacesWithBs && i < CapCountBool
+	case CapFamilyNum:
+		return i >= MagicCookieGlitchUl && i < CapCountNum
+	case CapFamilyString:
+		return i >= TermcapInit2 && i < CapCountString
+	}
+	return false
+}
+
+// extCaps is the registry of known extended capability names.
+var extCaps = struct {
+	db map[CapFamily]map[string]int
+	sync.RWMutex
+}{
+	db: map[CapFamily]map[string]int{
+		CapFamilyBool:   make(map[string]int),
+		CapFamilyNum:    make(map[string]int),
+		CapFamilyString: make(map[string]int),
+	},
+}
+
+// RegisterExtCap registers the extended cap name for the family, returning
+// the stable index of the name in the family's extension registry. Registering
+// a name more than once returns the same index. Returns -1 if family is
+// invalid.
+func RegisterExtCap(family CapFamily, name string) int {
+	extCaps.Lock()
+	defer extCaps.Unlock()
+	m, ok := extCaps.db[family]
+	if !ok {
+		return -1
+	}
+	if i, ok := m[name]; ok {
+		return i
+	}
+	i := len(m)
+	m[name] = i
+	return i
+}
+
+// ExtCapIndex returns the registered index of the extended cap name for the
+// family, or -1 if the name has not been registered.
+func ExtCapIndex(family CapFamily, name string) int {
+	extCaps.RLock()
+	defer extCaps.RUnlock()
+	if i, ok := extCaps.db[family][name]; ok {
+		return i
+	}
+	return -1
+}
+
+func init() {
+	// register the well known extended caps, as described in ncurses'
+	// user_caps(5) and used in its terminfo.src
+	for _, name := range []string{"AX", "G0", "NQ", "RGB", "Su", "Sxl", "Tc", "XF", "XT"} {
+		RegisterExtCap(CapFamilyBool, name)
+	}
+	for _, name := range []string{"RGB", "U8"} {
+		RegisterExtCap(CapFamilyNum, name)
+	}
+	for _, name := range []string{
+		"BD", "BE", "Clmg", "Cmg", "Cr", "Cs", "Dsbp", "Dsfr", "Dsmg", "E0",
+		"E3", "Enbp", "Enfr", "Enmg", "Ms", "PE", "PS", "RGB", "Rmol", "S0",
+		"Se", "Setulc", "Smol", "Smulx", "Ss", "Sync", "TS", "XM", "XR", "fd",
+		"fe", "grbom", "gsbom", "ka2", "kb1", "kb3", "kc2", "kcbt2", "kp5",
+		"kpADD", "kpCMA", "kpDIV", "kpDOT", "kpMUL", "kpSUB", "kpZRO", "kxIN",
+		"kxOUT", "rmxx", "smxx", "xm", "xr",
+	} {
+		RegisterExtCap(CapFamilyString, name)
+	}
+	// modified special keys, ie kUP (shift), kUP3 (alt), kUP5 (control)
+	for _, name := range []string{"kDC", "kDN", "kEND", "kFND", "kHOM", "kIC", "kLFT", "kNXT", "kPRV", "kRIT", "kUP"} {
+		RegisterExtCap(CapFamilyString, name)
+		for i := 2; i <= 8; i++ {
+			RegisterExtCap(CapFamilyString, name+strconv.Itoa(i))
+		}
+	}
+}
+
+// UnknownCaps returns the names of the extended caps that have not been
+// registered with RegisterExtCap (such as a misspelled cap name), sorted.
+func (ti *Terminfo) UnknownCaps() []string {
+	var names []string
+	for family, m := range map[CapFamily]map[int][]byte{
+		CapFamilyBool:   ti.ExtBoolNames,
+		CapFamilyNum:    ti.ExtNumNames,
+		CapFamilyString: ti.ExtStringNames,
+	} {
+		for _, name := range m {
+			if ExtCapIndex(family, string(name)) == -1 {
+				names = append(names, string(name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
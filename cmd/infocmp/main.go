@@ -9,7 +9,6 @@ import (
 	"os"
 	"sort"
 	"strings"
-	"unicode"
 
 	"github.com/xo/terminfo"
 )
@@ -28,7 +27,11 @@ func main() {
 	}
 
 	fmt.Printf("#\tReconstructed via %s from file: %s\n", strings.TrimPrefix(os.Args[0], "./"), ti.File)
-	fmt.Printf("%s,\n", strings.TrimSpace(strings.Join(ti.Names, "|")))
+	names := ti.Names
+	if ti.LongName != "" {
+		names = append(names, ti.LongName)
+	}
+	fmt.Printf("%s,\n", strings.TrimSpace(strings.Join(names, "|")))
 
 	process(ti.BoolCaps, ti.ExtBoolCaps, ti.BoolsM, terminfo.BoolCapName, nil)
 	process(
@@ -37,7 +40,7 @@ func main() {
 	)
 	process(
 		ti.StringCaps, ti.ExtStringCaps, ti.StringsM, terminfo.StringCapName,
-		func(v interface{}) string { return "=" + escape(v.([]byte)) },
+		func(v interface{}) string { return "=" + terminfo.EscapeCap(v.([]byte)) },
 	)
 }
 
@@ -106,73 +109,3 @@ func printIt(z interface{}, m map[int]bool, name func(int) string, mask func(int
 		fmt.Printf("\t%s%s,\n", n, x[n])
 	}
 }
-
-// peek peeks a byte.
-func peek(b []byte, pos, length int) byte {
-	if pos < length {
-		return b[pos]
-	}
-	return 0
-}
-
-func isprint(b byte) bool {
-	return unicode.IsPrint(rune(b))
-}
-
-func realprint(b byte) bool {
-	return b < 127 && isprint(b)
-}
-
-func iscntrl(b byte) bool {
-	return unicode.IsControl(rune(b))
-}
-
-func realctl(b byte) bool {
-	return b < 127 && iscntrl(b)
-}
-
-func isdigit(b byte) bool {
-	return unicode.IsDigit(rune(b))
-}
-
-// logic taken from _nc_tic_expand from ncurses-6.0/ncurses/tinfo/comp_expand.c
-func escape(buf []byte) string {
-	length := len(buf)
-	if length == 0 {
-		return ""
-	}
-
-	var s []byte
-	islong := length > 3
-	for i := 0; i < length; i++ {
-		ch := buf[i]
-		switch {
-		case ch == '%' && realprint(peek(buf, i+1, length)):
-			s = append(s, buf[i], buf[i+1])
-			i++
-
-		case ch == 128:
-			s = append(s, '\\', '0')
-
-		case ch == '\033':
-			s = append(s, '\\', 'E')
-
-		case realprint(ch) && ch != ',' && ch != ':' && ch != '!' && ch != '^':
-			s = append(s, ch)
-
-		case ch == '\r' && (islong || (i == length-1 && length > 2)):
-			s = append(s, '\\', 'r')
-
-		case ch == '\n' && islong:
-			s = append(s, '\\', 'n')
-
-		case realctl(ch) && ch != '\\' && (!islong || isdigit(peek(buf, i+1, length))):
-			s = append(s, '^', ch+'@')
-
-		default:
-			s = append(s, []byte(fmt.Sprintf("\\%03o", ch))...)
-		}
-	}
-
-	return string(s)
-}
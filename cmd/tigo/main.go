@@ -0,0 +1,182 @@
+// Application tigo generates Go source for terminfo entries, so that a program
+// can embed the capabilities of specific terminals without reading the
+// terminfo database at runtime.
+//
+// Usage:
+//
+//	tigo -pkg main -out terms.go xterm-256color vt100
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/xo/terminfo"
+)
+
+var (
+	flagPkg = flag.String("pkg", "main", "package name")
+	flagOut = flag.String("out", "", "out file (defaults to stdout)")
+)
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatal("no term names")
+	}
+	src, err := genSource(*flagPkg, flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *flagOut == "" {
+		_, err = os.Stdout.Write(src)
+	} else {
+		err = ioutil.WriteFile(*flagOut, src, 0o644)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// genSource generates the formatted Go source for package pkg, declaring a
+// variable for each of the term names.
+func genSource(pkg string, names []string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "// Code generated by tigo. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	fmt.Fprintf(buf, "import \"github.com/xo/terminfo\"\n")
+	vars := varNames(names)
+	for i, name := range names {
+		ti, err := terminfo.Load(name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load %s: %w", name, err)
+		}
+		generate(buf, vars[i], name, ti)
+	}
+	return format.Source(buf.Bytes())
+}
+
+// generate writes the Go literal for ti as the variable v.
+func generate(buf *bytes.Buffer, v, name string, ti *terminfo.Terminfo) {
+	fmt.Fprintf(buf, "\n// %s is the %s terminfo entry.\n", v, name)
+	fmt.Fprintf(buf, "var %s = &terminfo.Terminfo{\n", v)
+	fmt.Fprintf(buf, "Unaligned: %t,\n", ti.Unaligned)
+	fmt.Fprintf(buf, "BigEndian: %t,\n", ti.BigEndian)
+	fmt.Fprintf(buf, "Names: %#v,\n", ti.Names)
+	fmt.Fprintf(buf, "LongName: %q,\n", ti.LongName)
+	writeBools(buf, "Bools", ti.Bools, terminfo.BoolCapName)
+	writeBools(buf, "BoolsM", ti.BoolsM, terminfo.BoolCapName)
+	writeNums(buf, "Nums", ti.Nums, terminfo.NumCapName)
+	writeBools(buf, "NumsM", ti.NumsM, terminfo.NumCapName)
+	writeStrings(buf, "Strings", ti.Strings, terminfo.StringCapName)
+	writeBools(buf, "StringsM", ti.StringsM, terminfo.StringCapName)
+	writeBools(buf, "ExtBools", ti.ExtBools, ti.ExtBoolName)
+	writeStrings(buf, "ExtBoolNames", ti.ExtBoolNames, nil)
+	writeNums(buf, "ExtNums", ti.ExtNums, ti.ExtNumName)
+	writeStrings(buf, "ExtNumNames", ti.ExtNumNames, nil)
+	writeStrings(buf, "ExtStrings", ti.ExtStrings, ti.ExtStringName)
+	writeStrings(buf, "ExtStringNames", ti.ExtStringNames, nil)
+	fmt.Fprintf(buf, "}\n")
+}
+
+// writeBools writes the bool map field f.
+func writeBools(buf *bytes.Buffer, f string, m map[int]bool, name func(int) string) {
+	if m == nil {
+		return
+	}
+	fmt.Fprintf(buf, "%s: map[int]bool{\n", f)
+	for _, k := range keys(m) {
+		fmt.Fprintf(buf, "%d: %t, // %s\n", k, m[k], name(k))
+	}
+	fmt.Fprintf(buf, "},\n")
+}
+
+// writeNums writes the num map field f.
+func writeNums(buf *bytes.Buffer, f string, m map[int]int, name func(int) string) {
+	if m == nil {
+		return
+	}
+	fmt.Fprintf(buf, "%s: map[int]int{\n", f)
+	for _, k := range keys(m) {
+		fmt.Fprintf(buf, "%d: %d, // %s\n", k, m[k], name(k))
+	}
+	fmt.Fprintf(buf, "},\n")
+}
+
+// writeStrings writes the string map field f. The cap name comment is omitted
+// when name is nil.
+func writeStrings(buf *bytes.Buffer, f string, m map[int][]byte, name func(int) string) {
+	if m == nil {
+		return
+	}
+	fmt.Fprintf(buf, "%s: map[int][]byte{\n", f)
+	for _, k := range keys(m) {
+		if m[k] == nil {
+			fmt.Fprintf(buf, "%d: nil,", k)
+		} else {
+			fmt.Fprintf(buf, "%d: []byte(%s),", k, strconv.Quote(string(m[k])))
+		}
+		if name != nil {
+			fmt.Fprintf(buf, " // %s", name(k))
+		}
+		fmt.Fprintf(buf, "\n")
+	}
+	fmt.Fprintf(buf, "},\n")
+}
+
+// keys returns the sorted keys of m.
+func keys[T any](m map[int]T) []int {
+	z := make([]int, 0, len(m))
+	for k := range m {
+		z = append(z, k)
+	}
+	sort.Ints(z)
+	return z
+}
+
+// varNames returns unique exported Go variable names for the term names. When
+// the names of different terms are the same (ie, xterm-256color and
+// xterm+256color), a numeric suffix is added to the later names.
+func varNames(names []string) []string {
+	vars := make([]string, len(names))
+	seen := make(map[string]bool)
+	for i, name := range names {
+		v := varName(name)
+		for n := 2; seen[v]; n++ {
+			v = varName(name) + strconv.Itoa(n)
+		}
+		seen[v], vars[i] = true, v
+	}
+	return vars
+}
+
+// varName returns the exported Go variable name for the term name, ie
+// xterm-256color becomes Xterm256color.
+func varName(name string) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if upper {
+				r, upper = unicode.ToUpper(r), false
+			}
+			sb.WriteRune(r)
+		default:
+			upper = true
+		}
+	}
+	s := sb.String()
+	if s == "" || unicode.IsDigit(rune(s[0])) {
+		s = "Term" + s
+	}
+	return s
+}
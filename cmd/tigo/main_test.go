@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestVarNames(t *testing.T) {
+	names := []string{"xterm-256color", "xterm+256color", "xterm256color", "xterm2562color", "9term", "vt100"}
+	exp := []string{"Xterm256color", "Xterm256color2", "Xterm256color3", "Xterm2562color", "Term9term", "Vt100"}
+	if vars := varNames(names); !reflect.DeepEqual(vars, exp) {
+		t.Errorf("expected %v, got: %v", exp, vars)
+	}
+}
+
+func TestGenSource(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go not installed")
+	}
+	src, err := genSource("main", []string{"xterm-256color", "vt100", "dumb", "xterm-256color"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	// the generated package must be in the module to import terminfo
+	dir, err := os.MkdirTemp(".", "tigo-test")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, "terms.go"), src, 0o644); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	main := []byte("package main\n\nfunc main() {\n\t_, _, _, _ = Xterm256color, Vt100, Dumb, Xterm256color2\n}\n")
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), main, 0o644); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if out, err := exec.Command(goBin, "vet", "./"+dir).CombinedOutput(); err != nil {
+		t.Fatalf("expected generated source to compile, got: %v\n%s", err, out)
+	}
+}
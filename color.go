@@ -86,3 +86,174 @@ func ColorLevelFromEnv() (ColorLevel, error) {
 	}
 	return ColorLevelBasic, nil
 }
+
+// maxCachedColors is the max number of colors precomputed by a ColorCache.
+const maxCachedColors = 256
+
+// ColorCache holds the precomputed set_a_foreground (setaf) and
+// set_a_background (setab) sequences for a terminal's color palette.
+type ColorCache struct {
+	setaf, setab []byte
+	maxColors    int
+	fg, bg       [][]byte
+}
+
+// ColorCache returns a color cache for the terminal, precomputing the
+// sequences for the first max_colors colors (up to 256). The cache is a
+// snapshot of the terminal's caps, and needs to be recreated if the caps are
+// changed.
+func (ti *Terminfo) ColorCache() *ColorCache {
+	c := &ColorCache{
+		setaf:     ti.Strings[SetAForeground],
+		setab:     ti.Strings[SetABackground],
+		maxColors: ti.Num(MaxColors),
+	}
+	n := c.maxColors
+	if n > maxCachedColors {
+		n = maxCachedColors
+	}
+	if c.setaf != nil && n > 0 {
+		c.fg = make([][]byte, n)
+		for i := range c.fg {
+			c.fg[i] = []byte(Printf(c.setaf, i))
+		}
+	}
+	if c.setab != nil && n > 0 {
+		c.bg = make([][]byte, n)
+		for i := range c.bg {
+			c.bg[i] = []byte(Printf(c.setab, i))
+		}
+	}
+	return c
+}
+
+// Fg returns the sequence that sets the foreground to color, or nil if the
+// color is not supported.
+func (c *ColorCache) Fg(color int) []byte {
+	return c.get(c.fg, c.setaf, color)
+}
+
+// Bg returns the sequence that sets the background to color, or nil if the
+// color is not supported.
+func (c *ColorCache) Bg(color int) []byte {
+	return c.get(c.bg, c.setab, color)
+}
+
+// get returns the cached sequence for color, evaluating z for colors past the
+// cached colors.
+func (c *ColorCache) get(cache [][]byte, z []byte, color int) []byte {
+	switch {
+	case z == nil || color < 0 || color >= c.maxColors:
+		return nil
+	case color < len(cache):
+		return cache[color]
+	}
+	return []byte(Printf(z, color))
+}
+
+// VideoAttrs is a set of video attributes, using the bit positions of the
+// no_color_video (ncv) cap, as described in terminfo(5).
+type VideoAttrs uint
+
+// VideoAttrs values.
+const (
+	VideoStandout VideoAttrs = 1 << iota
+	VideoUnderline
+	VideoReverse
+	VideoBlink
+	VideoDim
+	VideoBold
+	VideoInvisible
+	VideoProtect
+	VideoAltCharset
+	VideoHorizontal
+	VideoLeft
+	VideoLow
+	VideoRight
+	VideoTop
+	VideoVertical
+	VideoItalic
+)
+
+// Has determines if all the attributes in a are set.
+func (v VideoAttrs) Has(a VideoAttrs) bool {
+	return v&a == a
+}
+
+// NoColorVideo returns the video attributes that cannot be combined with
+// color, as decoded from the no_color_video (ncv) cap. Returns no attributes
+// when ncv is not defined.
+func (ti *Terminfo) NoColorVideo() VideoAttrs {
+	n := ti.Num(NoColorVideo)
+	if n < 0 {
+		return 0
+	}
+	return VideoAttrs(n)
+}
+
+// ColorModel is a terminal's color model.
+type ColorModel uint
+
+// ColorModel values.
+const (
+	// ColorModelNone is no color support.
+	ColorModelNone ColorModel = iota
+	// ColorModelStandard is the 8 standard colors, set with setaf/setab (as
+	// ANSI \E[3Xm) or setf/setb.
+	ColorModelStandard
+	// ColorModelAixterm is the 8 standard colors and their 8 aixterm high
+	// intensity variants (as \E[9Xm).
+	ColorModelAixterm
+	// ColorModel256 is an indexed palette of 256 (or 88) colors (as
+	// \E[38;5;Nm).
+	ColorModel256
+	// ColorModelDirect is direct (24-bit) color (as \E[38;2;R;G;Bm).
+	ColorModelDirect
+)
+
+// String satisfies the Stringer interface.
+func (m ColorModel) String() string {
+	switch m {
+	case ColorModelStandard:
+		return "standard"
+	case ColorModelAixterm:
+		return "aixterm"
+	case ColorModel256:
+		return "256"
+	case ColorModelDirect:
+		return "direct"
+	}
+	return "none"
+}
+
+// ColorModel returns the terminal's color model, as determined from the
+// max_colors (colors) cap, the set_a_foreground (setaf) and set_foreground
+// (setf) caps, and the extended RGB cap used by ncurses' direct color
+// entries.
+func (ti *Terminfo) ColorModel() ColorModel {
+	colors := ti.Num(MaxColors)
+	setaf, setf := ti.Strings[SetAForeground], ti.Strings[SetForeground]
+	switch {
+	case colors <= 0 || (setaf == nil && setf == nil):
+		return ColorModelNone
+	case setaf == nil:
+		return ColorModelStandard
+	case colors >= 1<<24 || ti.hasExtCap("RGB"):
+		return ColorModelDirect
+	case colors >= 88:
+		return ColorModel256
+	case colors >= 16:
+		return ColorModelAixterm
+	}
+	return ColorModelStandard
+}
+
+// hasExtCap determines if the extended cap name is present in any family.
+func (ti *Terminfo) hasExtCap(name string) bool {
+	for _, names := range []map[int][]byte{ti.ExtBoolNames, ti.ExtNumNames, ti.ExtStringNames} {
+		if _, ok := extIndex(names, name); ok {
+			return true
+		}
+	}
+	return false
+}
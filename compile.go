@@ -0,0 +1,192 @@
+package terminfo
+
+import (
+	"bytes"
+)
+
+// opcode is a compiled parameterized string instruction.
+type opcode uint8
+
+// opcode values.
+const (
+	// opText writes text.
+	opText opcode = iota
+	// opFormat pops and writes a value with the verb ch, using the format f.
+	opFormat
+	// opParam pushes parameter n.
+	opParam
+	// opConst pushes v.
+	opConst
+	// opSetVar pops and sets variable ch.
+	opSetVar
+	// opGetVar pushes variable ch.
+	opGetVar
+	// opApply applies operator ch.
+	opApply
+	// opJumpFalse pops a bool, and jumps to instruction n if false.
+	opJumpFalse
+	// opJump jumps to instruction n.
+	opJump
+)
+
+// instr is a compiled parameterized string instruction.
+type instr struct {
+	op   opcode
+	ch   byte
+	n    int
+	v    interface{}
+	f    string
+	text []byte
+}
+
+// CompiledCap is a parameterized string cap compiled to a list of
+// instructions, that can be evaluated repeatedly without rescanning the
+// string.
+type CompiledCap struct {
+	instrs []instr
+}
+
+// Compile compiles the string cap i. Returns a *ParamError if the cap is
+// malformed, or ErrInvalidCapIndex if i is not a string cap.
+func (ti *Terminfo) Compile(i int) (CompiledCap, error) {
+	if i < 0 || i >= CapCountString {
+		return CompiledCap{}, ErrInvalidCapIndex
+	}
+	instrs, err := compile(ti.Strings[i])
+	if err != nil {
+		err.(*ParamError).Cap = StringCapName(i)
+		return CompiledCap{}, err
+	}
+	return CompiledCap{instrs: instrs}, nil
+}
+
+// Exec evaluates the compiled cap, interpolating params. The output is the
+// same as Printf for the cap, and static variables are shared with Printf.
+func (c CompiledCap) Exec(params ...interface{}) string {
+	return staticVars.Exec(c, params...)
+}
+
+// Exec evaluates the compiled cap c, interpolating params, and reading and
+// setting the static variables of the context.
+func (ctx *ParamContext) Exec(c CompiledCap, params ...interface{}) string {
+	p := newParametizer(ctx, nil)
+	defer p.reset()
+	for i := 0; i < len(p.params) && i < len(params); i++ {
+		p.params[i] = params[i]
+	}
+	for pc := 0; pc < len(c.instrs); pc++ {
+		in := &c.instrs[pc]
+		switch in.op {
+		case opText:
+			p.buf.Write(in.text)
+		case opFormat:
+			p.format(in.f, in.ch)
+		case opParam:
+			p.s.push(p.params[in.n])
+		case opConst:
+			p.s.push(in.v)
+		case opSetVar:
+			p.setVar(in.ch)
+		case opGetVar:
+			p.getVar(in.ch)
+		case opApply:
+			p.apply(in.ch)
+		case opJumpFalse:
+			if !p.s.popBool() {
+				pc = in.n - 1
+			}
+		case opJump:
+			pc = in.n - 1
+		}
+	}
+	return p.buf.String()
+}
+
+// compile compiles the parameterized terminfo value z.
+func compile(z []byte) ([]instr, error) {
+	if _, err := analyzeParams(z); err != nil {
+		return nil, err
+	}
+	var instrs []instr
+	// conds are the open conditionals, with the position of the pending
+	// %t jump and the %e jumps to the end of the conditional
+	type cond struct {
+		jz   int
+		jmps []int
+	}
+	var conds []cond
+	emit := func(in instr) {
+		instrs = append(instrs, in)
+	}
+	for i := 0; i < len(z); i++ {
+		j := bytes.IndexByte(z[i:], '%')
+		if j == -1 {
+			emit(instr{op: opText, text: z[i:]})
+			break
+		}
+		if j != 0 {
+			emit(instr{op: opText, text: z[i : i+j]})
+		}
+		// analyzeParams has already validated the sequences, so the bounds
+		// do not need to be checked
+		i += j + 1
+		switch ch := z[i]; ch {
+		case '%':
+			emit(instr{op: opText, text: z[i : i+1]})
+		case ':', '#', ' ', '.', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			if ch == ':' {
+				i++
+			}
+			start := i
+			for bytes.IndexByte([]byte("doxXsc"), z[i]) == -1 {
+				i++
+			}
+			emit(instr{op: opFormat, ch: z[i], f: "%" + string(z[start:i+1])})
+		case 'o', 'd', 'x', 'X', 's', 'c':
+			emit(instr{op: opFormat, ch: ch})
+		case 'p':
+			i++
+			emit(instr{op: opParam, n: int(z[i] - '1')})
+		case 'P':
+			i++
+			emit(instr{op: opSetVar, ch: z[i]})
+		case 'g':
+			i++
+			emit(instr{op: opGetVar, ch: z[i]})
+		case '\'':
+			emit(instr{op: opConst, v: z[i+1]})
+			i += 2
+		case '{':
+			var n int
+			for i++; z[i] >= '0' && z[i] <= '9'; i++ {
+				n = n*10 + int(z[i]-'0')
+			}
+			emit(instr{op: opConst, v: n})
+		case '?':
+			conds = append(conds, cond{jz: -1})
+		case 't':
+			c := &conds[len(conds)-1]
+			c.jz = len(instrs)
+			emit(instr{op: opJumpFalse})
+		case 'e':
+			c := &conds[len(conds)-1]
+			c.jmps = append(c.jmps, len(instrs))
+			emit(instr{op: opJump})
+			if c.jz != -1 {
+				instrs[c.jz].n, c.jz = len(instrs), -1
+			}
+		case ';':
+			c := conds[len(conds)-1]
+			conds = conds[:len(conds)-1]
+			if c.jz != -1 {
+				instrs[c.jz].n = len(instrs)
+			}
+			for _, k := range c.jmps {
+				instrs[k].n = len(instrs)
+			}
+		default:
+			emit(instr{op: opApply, ch: ch})
+		}
+	}
+	return instrs, nil
+}
@@ -1,12 +1,13 @@
 package terminfo
 
 import (
-	"sort"
+	"bytes"
 )
 
 const (
-	// maxFileLength is the max file length.
-	maxFileLength = 4096
+	// maxFileLength is the max file length (ncurses' MAX_ENTRY_SIZE when built
+	// with the extended number format).
+	maxFileLength = 32768
 	// magic is the file magic for terminfo files.
 	magic = 0o432
 	// magicExtended is the file magic for terminfo files with the extended
@@ -33,45 +34,93 @@ const (
 	fieldExtTableSize
 )
 
-// hasInvalidCaps determines if the capabilities in h are invalid.
-func hasInvalidCaps(h []int) bool {
-	return h[fieldBoolCount] > CapCountBool ||
-		h[fieldNumCount] > CapCountNum ||
-		h[fieldStringCount] > CapCountString
+// isBigEndian determines if the file in buf is big endian, from its magic. Some
+// historical SysV systems wrote terminfo files with their native (big
+// endian) byte order.
+func isBigEndian(buf []byte) bool {
+	if len(buf) < 2 {
+		return false
+	}
+	m := int(buf[0])<<8 | int(buf[1])
+	return m == magic || m == magicExtended
+}
+
+// headerFields are the names of the header fields.
+var headerFields = [...]string{"magic", "name size", "bool count", "num count", "string count", "string table size"}
+
+// extHeaderFields are the names of the extended header fields.
+var extHeaderFields = [...]string{"bool count", "num count", "string count", "offset count", "string table size"}
+
+// checkHeader checks that the section sizes and counts of the header h are
+// valid, returning a *HeaderError for the first invalid field.
+func checkHeader(h []int) error {
+	for i := fieldNameSize; i < len(h); i++ {
+		if h[i] < 0 {
+			return &HeaderError{Err: ErrInvalidHeader, Field: headerFields[i], Value: h[i]}
+		}
+	}
+	for _, f := range []struct{ i, max int }{
+		{fieldBoolCount, CapCountBool},
+		{fieldNumCount, CapCountNum},
+		{fieldStringCount, CapCountString},
+	} {
+		if h[f.i] > f.max {
+			return &HeaderError{Err: ErrInvalidHeader, Field: headerFields[f.i], Value: h[f.i]}
+		}
+	}
+	return nil
 }
 
 // capLength returns the total length of the capabilities in bytes.
-func capLength(h []int) int {
+func (d *decoder) capLength(h []int) int {
 	return h[fieldNameSize] +
 		h[fieldBoolCount] +
-		(h[fieldNameSize]+h[fieldBoolCount])%2 + // account for word align
+		d.pad(h[fieldNameSize]+h[fieldBoolCount]) + // account for word align
 		h[fieldNumCount]*2 +
 		h[fieldStringCount]*2 +
 		h[fieldTableSize]
 }
 
-// hasInvalidExtOffset determines if the extended offset field is valid.
-func hasInvalidExtOffset(h []int) bool {
-	return h[fieldExtBoolCount]+
-		h[fieldExtNumCount]+
-		h[fieldExtStringCount]*2 != h[fieldExtOffsetCount]
+// checkExtHeader checks that the counts and sizes of the extended header h
+// are valid, returning a *HeaderError for the first invalid field.
+func checkExtHeader(h []int) error {
+	for i, v := range h {
+		if v < 0 {
+			return &HeaderError{Err: ErrInvalidExtendedHeader, Field: extHeaderFields[i], Value: v}
+		}
+	}
+	// the offset field only counts the used offsets, so may be smaller
+	if h[fieldExtOffsetCount] > extOffsetCount(h) {
+		return &HeaderError{Err: ErrInvalidExtendedHeader, Field: extHeaderFields[fieldExtOffsetCount], Value: h[fieldExtOffsetCount]}
+	}
+	return nil
+}
+
+// extOffsetCount returns the number of extended string table offsets (string
+// values and the bool, num and string names). The extended offset field only
+// counts the offsets that are used, and can be smaller than this.
+func extOffsetCount(h []int) int {
+	return h[fieldExtBoolCount] +
+		h[fieldExtNumCount] +
+		h[fieldExtStringCount]*2
 }
 
 // extCapLength returns the total length of extended capabilities in bytes.
-func extCapLength(h []int, numWidth int) int {
+func (d *decoder) extCapLength(h []int, numWidth int) int {
 	return h[fieldExtBoolCount] +
-		h[fieldExtBoolCount]%2 + // account for word align
+		d.pad(h[fieldExtBoolCount]) + // account for word align
 		h[fieldExtNumCount]*(numWidth/8) +
-		h[fieldExtOffsetCount]*2 +
+		extOffsetCount(h)*2 +
 		h[fieldExtTableSize]
 }
 
-// findNull finds the position of null in buf.
+// findNull finds the position of null in buf, starting at i.
 func findNull(buf []byte, i int) int {
-	for ; i < len(buf); i++ {
-		if buf[i] == 0 {
-			return i
-		}
+	if i >= len(buf) {
+		return -1
+	}
+	if j := bytes.IndexByte(buf[i:], 0); j != -1 {
+		return i + j
 	}
 	return -1
 }
@@ -99,11 +148,23 @@ type decoder struct {
 	buf []byte
 	pos int
 	n   int
+	// unaligned is whether the file omits the alignment bytes.
+	unaligned bool
+	// bigEndian is whether the file's ints are big endian.
+	bigEndian bool
+}
+
+// pad returns the number of alignment bytes following n bytes.
+func (d *decoder) pad(n int) int {
+	if d.unaligned {
+		return 0
+	}
+	return n % 2
 }
 
 // readBytes reads the next n bytes of buf, incrementing pos by n.
 func (d *decoder) readBytes(n int) ([]byte, error) {
-	if d.n < d.pos+n {
+	if n < 0 || d.n < d.pos+n {
 		return nil, ErrUnexpectedFileEnd
 	}
 	n, d.pos = d.pos, d.pos+n
@@ -119,16 +180,20 @@ func (d *decoder) readInts(n, w int) ([]int, error) {
 		return nil, err
 	}
 	// align
-	d.pos += d.pos % 2
+	d.pos += d.pad(d.pos)
 	z := make([]int, n)
 	for i, j := 0, 0; i < l; i, j = i+w, j+1 {
-		switch w {
-		case 1:
+		switch {
+		case w == 1:
 			z[i] = int(buf[i])
-		case 2:
+		case w == 2 && d.bigEndian:
+			z[j] = int(int16(buf[i])<<8 | int16(buf[i+1]))
+		case w == 2:
 			z[j] = int(int16(buf[i+1])<<8 | int16(buf[i]))
-		case 4:
-			z[j] = int(buf[i+3])<<24 | int(buf[i+2])<<16 | int(buf[i+1])<<8 | int(buf[i])
+		case w == 4 && d.bigEndian:
+			z[j] = int(int32(buf[i])<<24 | int32(buf[i+1])<<16 | int32(buf[i+2])<<8 | int32(buf[i+3]))
+		case w == 4:
+			z[j] = int(int32(buf[i+3])<<24 | int32(buf[i+2])<<16 | int32(buf[i+1])<<8 | int32(buf[i]))
 		}
 	}
 	return z, nil
@@ -169,19 +234,20 @@ func (d *decoder) readNums(n, w int) (map[int]int, map[int]bool, error) {
 }
 
 // readStringTable reads the string data for n strings and the accompanying data
-// table of length sz.
-func (d *decoder) readStringTable(n, sz int) ([][]byte, []int, error) {
+// table of length sz, returning the strings, the cancelled strings and the
+// data table.
+func (d *decoder) readStringTable(n, sz int) ([][]byte, []int, []byte, error) {
 	buf, err := d.readInts(n, 16)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	// read string data table
 	data, err := d.readBytes(sz)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	// align
-	d.pos += d.pos % 2
+	d.pos += d.pad(d.pos)
 	// process
 	s := make([][]byte, n)
 	var m []int
@@ -193,19 +259,19 @@ func (d *decoder) readStringTable(n, sz int) ([][]byte, []int, error) {
 			if end := findNull(data, start); end != -1 {
 				s[i] = data[start:end]
 			} else {
-				return nil, nil, ErrInvalidStringTable
+				return nil, nil, nil, ErrInvalidStringTable
 			}
 		}
 	}
-	return s, m, nil
+	return s, m, data, nil
 }
 
 // readStrings reads the next n strings and processes the string data table of
-// length sz.
-func (d *decoder) readStrings(n, sz int) (map[int][]byte, map[int]bool, error) {
-	s, m, err := d.readStringTable(n, sz)
+// length sz, returning the strings, the cancelled strings and the data table.
+func (d *decoder) readStrings(n, sz int) (map[int][]byte, map[int]bool, []byte, error) {
+	s, m, data, err := d.readStringTable(n, sz)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	strs := make(map[int][]byte)
 	for k, v := range s {
@@ -218,28 +284,35 @@ func (d *decoder) readStrings(n, sz int) (map[int][]byte, map[int]bool, error) {
 	for _, k := range m {
 		strsM[k] = true
 	}
-	return strs, strsM, nil
+	return strs, strsM, data, nil
 }
 
-// canonicalizeAscChars reorders chars to be unique, in order.
+// canonicalizeAscChars reorders the acs_chars pairs to be unique, in order of
+// the vt100 char. As with ncurses, later pairs override earlier pairs for the
+// same char, and a trailing unpaired char is kept at the end.
 //
-// see repair_ascc in ncurses-6.3/progs/dump_entry.c
+// see repair_acsc in ncurses-6.3/progs/dump_entry.c
 func canonicalizeAscChars(z []byte) []byte {
-	var c []byte
-	enc := make(map[byte]byte, len(z)/2)
+	if z == nil {
+		return nil
+	}
+	var enc [256]byte
+	var extra byte
 	for i := 0; i < len(z); i += 2 {
-		if _, ok := enc[z[i]]; !ok {
-			a, b := z[i], z[i+1]
-			// log.Printf(">>> a: %d %c, b: %d %c", a, a, b, b)
-			c, enc[a] = append(c, b), b
+		if i+1 == len(z) {
+			extra = z[i]
+			break
+		}
+		enc[z[i]] = z[i+1]
+	}
+	r := make([]byte, 0, len(z))
+	for a, b := range enc {
+		if b != 0 {
+			r = append(r, byte(a), b)
 		}
 	}
-	sort.Slice(c, func(i, j int) bool {
-		return c[i] < c[j]
-	})
-	r := make([]byte, 2*len(c))
-	for i := 0; i < len(c); i++ {
-		r[i*2], r[i*2+1] = c[i], enc[c[i]]
+	if extra != 0 {
+		r = append(r, extra)
 	}
 	return r
 }
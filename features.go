@@ -0,0 +1,69 @@
+package terminfo
+
+// Feature is a terminal feature, made up of one or more string caps.
+type Feature uint
+
+// Feature values.
+const (
+	// FeatureColor is setting colors, with set_a_foreground (setaf),
+	// set_a_background (setab) and orig_pair (op).
+	FeatureColor Feature = iota
+	// FeatureAltScreen is the alternate screen, with enter_ca_mode (smcup)
+	// and exit_ca_mode (rmcup).
+	FeatureAltScreen
+	// FeatureMouse is mouse support, with key_mouse (kmous).
+	FeatureMouse
+	// FeatureCursorAddress is moving the cursor, with cursor_address (cup).
+	FeatureCursorAddress
+	// FeatureCursorVisibility is hiding and showing the cursor, with
+	// cursor_invisible (civis) and cursor_normal (cnorm).
+	FeatureCursorVisibility
+	// FeatureKeypad is switching the keypad to transmit mode, with
+	// keypad_xmit (smkx) and keypad_local (rmkx).
+	FeatureKeypad
+)
+
+// String satisfies the Stringer interface.
+func (f Feature) String() string {
+	switch f {
+	case FeatureColor:
+		return "color"
+	case FeatureAltScreen:
+		return "alt screen"
+	case FeatureMouse:
+		return "mouse"
+	case FeatureCursorAddress:
+		return "cursor address"
+	case FeatureCursorVisibility:
+		return "cursor visibility"
+	case FeatureKeypad:
+		return "keypad"
+	}
+	return "unknown"
+}
+
+// featureCaps are the string caps required for each feature.
+var featureCaps = map[Feature][]int{
+	FeatureColor:            {SetAForeground, SetABackground, OrigPair},
+	FeatureAltScreen:        {EnterCaMode, ExitCaMode},
+	FeatureMouse:            {KeyMouse},
+	FeatureCursorAddress:    {CursorAddress},
+	FeatureCursorVisibility: {CursorInvisible, CursorNormal},
+	FeatureKeypad:           {KeypadXmit, KeypadLocal},
+}
+
+// RequiredFor returns the string caps the terminal defines for the feature,
+// reporting whether all the caps required for the feature are defined.
+func (ti *Terminfo) RequiredFor(feature Feature) ([]int, bool) {
+	caps, ok := featureCaps[feature]
+	if !ok {
+		return nil, false
+	}
+	var z []int
+	for _, i := range caps {
+		if ti.Strings[i] != nil {
+			z = append(z, i)
+		}
+	}
+	return z, len(z) == len(caps)
+}
@@ -4,6 +4,8 @@ import (
 	"os"
 	"os/user"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -16,9 +18,30 @@ var termCache = struct {
 	db: make(map[string]*Terminfo),
 }
 
+// termLoad is an in-flight load of a terminfo file.
+type termLoad struct {
+	ti  *Terminfo
+	err error
+	sync.WaitGroup
+}
+
+// termLoads are the in-flight loads, used to collapse concurrent loads of the
+// same name into a single file read.
+var termLoads = struct {
+	db map[string]*termLoad
+	sync.Mutex
+}{
+	db: make(map[string]*termLoad),
+}
+
 // Load follows the behavior described in terminfo(5) to find correct the
 // terminfo file using the name, reads the file and then returns a Terminfo
 // struct that describes the file.
+//
+// Directories are checked in the following order: $TERMINFO, $HOME/.terminfo,
+// $TERMINFO_DIRS, and then /etc/terminfo, /lib/terminfo and
+// /usr/share/terminfo (see SetPreferLocal and SetCacheDirListings). The File
+// field of the returned Terminfo is the file that was loaded.
 func Load(name string) (*Terminfo, error) {
 	if name == "" {
 		return nil, ErrEmptyTermName
@@ -29,6 +52,60 @@ func Load(name string) (*Terminfo, error) {
 	if ok {
 		return ti, nil
 	}
+	// wait on an in-flight load of the same name
+	termLoads.Lock()
+	if l, ok := termLoads.db[name]; ok {
+		termLoads.Unlock()
+		l.Wait()
+		return l.ti, l.err
+	}
+	// recheck the cache, in case a load finished in the meantime
+	termCache.RLock()
+	ti, ok = termCache.db[name]
+	termCache.RUnlock()
+	if ok {
+		termLoads.Unlock()
+		return ti, nil
+	}
+	l := new(termLoad)
+	l.Add(1)
+	termLoads.db[name] = l
+	termLoads.Unlock()
+	l.ti, l.err = load(name)
+	l.Done()
+	termLoads.Lock()
+	delete(termLoads.db, name)
+	termLoads.Unlock()
+	return l.ti, l.err
+}
+
+// loadOpts are the options used when loading terminfo files.
+var loadOpts = struct {
+	preferLocal      bool
+	cacheDirListings bool
+	sync.RWMutex
+}{
+	preferLocal: true,
+}
+
+// SetPreferLocal sets whether the site-local /etc/terminfo directory is
+// checked before the system /lib/terminfo and /usr/share/terminfo directories,
+// as ncurses does by default (the default is true). Changing it clears the
+// cache of loaded terminfo, so that later loads use the new order.
+func SetPreferLocal(preferLocal bool) {
+	loadOpts.Lock()
+	changed := loadOpts.preferLocal != preferLocal
+	loadOpts.preferLocal = preferLocal
+	loadOpts.Unlock()
+	if changed {
+		termCache.Lock()
+		termCache.db = make(map[string]*Terminfo)
+		termCache.Unlock()
+	}
+}
+
+// dirs returns the terminfo directories to check, in order of precedence.
+func dirs() ([]string, error) {
 	var checkDirs []string
 	// check $TERMINFO
 	if dir := os.Getenv("TERMINFO"); dir != "" {
@@ -45,9 +122,82 @@ func Load(name string) (*Terminfo, error) {
 		checkDirs = append(checkDirs, strings.Split(dirs, ":")...)
 	}
 	// check fallback directories
-	checkDirs = append(checkDirs, "/etc/terminfo", "/lib/terminfo", "/usr/share/terminfo")
+	loadOpts.RLock()
+	preferLocal := loadOpts.preferLocal
+	loadOpts.RUnlock()
+	if preferLocal {
+		checkDirs = append(checkDirs, "/etc/terminfo", "/lib/terminfo", "/usr/share/terminfo")
+	} else {
+		checkDirs = append(checkDirs, "/lib/terminfo", "/usr/share/terminfo", "/etc/terminfo")
+	}
+	return checkDirs, nil
+}
+
+// SetCacheDirListings sets whether Load caches the listings of the terminfo
+// directories, skipping directories that do not contain the requested name
+// without trying to read the file (the default is false). This helps on large
+// or slow (ie, network mounted) databases, but entries added to a directory
+// after it was first listed are not found until ResetDirListings is called.
+func SetCacheDirListings(cacheDirListings bool) {
+	loadOpts.Lock()
+	defer loadOpts.Unlock()
+	loadOpts.cacheDirListings = cacheDirListings
+}
+
+// dirListings is the cache of terminfo directory listings.
+var dirListings = struct {
+	db map[string]map[string]bool
+	sync.Mutex
+}{
+	db: make(map[string]map[string]bool),
+}
+
+// ResetDirListings clears the cached terminfo directory listings.
+func ResetDirListings() {
+	dirListings.Lock()
+	defer dirListings.Unlock()
+	dirListings.db = make(map[string]map[string]bool)
+}
+
+// dirHas determines if the terminfo directory dir has an entry for name,
+// using the cached listings of its subdirectories.
+func dirHas(dir, name string) bool {
+	dirListings.Lock()
+	defer dirListings.Unlock()
+	for _, sub := range []string{
+		path.Join(dir, name[0:1]),
+		path.Join(dir, strconv.FormatUint(uint64(name[0]), 16)),
+	} {
+		m, ok := dirListings.db[sub]
+		if !ok {
+			m = make(map[string]bool)
+			entries, _ := os.ReadDir(sub)
+			for _, e := range entries {
+				m[e.Name()] = true
+			}
+			dirListings.db[sub] = m
+		}
+		if m[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// load finds and opens the terminfo file for name.
+func load(name string) (*Terminfo, error) {
+	checkDirs, err := dirs()
+	if err != nil {
+		return nil, err
+	}
+	loadOpts.RLock()
+	cacheDirListings := loadOpts.cacheDirListings
+	loadOpts.RUnlock()
 	for _, dir := range checkDirs {
-		ti, err = Open(dir, name)
+		if cacheDirListings && !dirHas(dir, name) {
+			continue
+		}
+		ti, err := Open(dir, name)
 		if err != nil && err != ErrFileNotFound && !os.IsNotExist(err) {
 			return nil, err
 		} else if err == nil {
@@ -57,8 +207,117 @@ func Load(name string) (*Terminfo, error) {
 	return nil, ErrDatabaseDirectoryNotFound
 }
 
+// LoadPrefix loads all the terminals whose primary name starts with prefix
+// (ie, all xterm variants with xterm), from the directories checked by Load.
+// The terminals are returned sorted by name. Files that cannot be loaded (such
+// as invalid terminfo files) are skipped.
+func LoadPrefix(prefix string) ([]*Terminfo, error) {
+	if prefix == "" {
+		return nil, ErrEmptyTermName
+	}
+	checkDirs, err := dirs()
+	if err != nil {
+		return nil, err
+	}
+	// collect the names
+	names := make(map[string]bool)
+	for _, dir := range checkDirs {
+		for _, sub := range []string{
+			path.Join(dir, prefix[0:1]),
+			path.Join(dir, strconv.FormatUint(uint64(prefix[0]), 16)),
+		} {
+			entries, _ := os.ReadDir(sub)
+			for _, e := range entries {
+				if n := e.Name(); !e.IsDir() && strings.HasPrefix(n, prefix) {
+					names[n] = true
+				}
+			}
+		}
+	}
+	keys := make([]string, 0, len(names))
+	for name := range names {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	// load, skipping aliases and files that cannot be loaded
+	var tis []*Terminfo
+	seen := make(map[string]bool)
+	for _, name := range keys {
+		ti, err := Load(name)
+		if err != nil {
+			continue
+		}
+		if len(ti.Names) == 0 || !strings.HasPrefix(ti.Names[0], prefix) || seen[ti.Names[0]] {
+			continue
+		}
+		seen[ti.Names[0]] = true
+		tis = append(tis, ti)
+	}
+	sort.Slice(tis, func(i, j int) bool {
+		return tis[i].Names[0] < tis[j].Names[0]
+	})
+	return tis, nil
+}
+
 // LoadFromEnv loads the terminal info based on the name contained in
 // environment variable TERM.
 func LoadFromEnv() (*Terminfo, error) {
 	return Load(os.Getenv("TERM"))
 }
+
+// LoadForFile loads the terminal info for the terminal device f, based on the
+// name contained in environment variable TERM. Returns ErrNotTerminal when f
+// is not a character device.
+func LoadForFile(f *os.File) (*Terminfo, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Mode()&os.ModeCharDevice == 0 {
+		return nil, ErrNotTerminal
+	}
+	return LoadFromEnv()
+}
+
+// Database is the interface for a terminfo database.
+type Database interface {
+	// Load loads the terminfo for the terminal name.
+	Load(name string) (*Terminfo, error)
+}
+
+// SystemDB is the system terminfo database, loading terminfo files with Load.
+var SystemDB Database = systemDB{}
+
+// systemDB is the system terminfo database.
+type systemDB struct{}
+
+// Load satisfies the Database interface.
+func (systemDB) Load(name string) (*Terminfo, error) {
+	return Load(name)
+}
+
+// memDB is an in-memory terminfo database.
+type memDB map[string]*Terminfo
+
+// NewMemDB creates an in-memory terminfo database containing entries, keyed
+// by terminal name. Useful for testing code that loads terminfo without
+// touching the filesystem.
+func NewMemDB(entries map[string]*Terminfo) Database {
+	db := make(memDB, len(entries))
+	for name, ti := range entries {
+		db[name] = ti
+	}
+	return db
+}
+
+// Load satisfies the Database interface.
+func (db memDB) Load(name string) (*Terminfo, error) {
+	if name == "" {
+		return nil, ErrEmptyTermName
+	}
+	ti, ok := db[name]
+	if !ok {
+		return nil, ErrFileNotFound
+	}
+	return ti, nil
+}
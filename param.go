@@ -27,13 +27,21 @@ type parametizer struct {
 	params [9]interface{}
 	// vars are dynamic variables.
 	vars [26]interface{}
+	// ctx holds the static variables.
+	ctx *ParamContext
 }
 
-// staticVars are the static, global variables.
-var staticVars = struct {
+// ParamContext holds the static variables (%PA through %PZ) of parameterized
+// values, that persist between evaluations. Dynamic variables (%Pa through
+// %Pz) are always local to a single evaluation. The zero value is ready to
+// use.
+type ParamContext struct {
 	vars [26]interface{}
 	sync.Mutex
-}{}
+}
+
+// staticVars are the static, global variables, used by Printf and Fprintf.
+var staticVars = new(ParamContext)
 
 var parametizerPool = sync.Pool{
 	New: func() interface{} {
@@ -44,9 +52,9 @@ var parametizerPool = sync.Pool{
 }
 
 // newParametizer returns a new initialized parametizer from the pool.
-func newParametizer(z []byte) *parametizer {
+func newParametizer(ctx *ParamContext, z []byte) *parametizer {
 	p := parametizerPool.Get().(*parametizer)
-	p.z = z
+	p.z, p.ctx = z, ctx
 	return p
 }
 
@@ -56,6 +64,7 @@ func (p *parametizer) reset() {
 	p.s.reset()
 	p.buf.Reset()
 	p.params, p.vars = [9]interface{}{}, [26]interface{}{}
+	p.ctx = nil
 	parametizerPool.Put(p)
 }
 
@@ -123,18 +132,8 @@ func (p *parametizer) scanCodeFn() stateFn {
 		return p.scanFormatFn
 	case '#', ' ', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', '.':
 		return p.scanFormatFn
-	case 'o':
-		p.buf.WriteString(strconv.FormatInt(int64(p.s.popInt()), 8))
-	case 'd':
-		p.buf.WriteString(strconv.Itoa(p.s.popInt()))
-	case 'x':
-		p.buf.WriteString(strconv.FormatInt(int64(p.s.popInt()), 16))
-	case 'X':
-		p.buf.WriteString(strings.ToUpper(strconv.FormatInt(int64(p.s.popInt()), 16)))
-	case 's':
-		p.buf.WriteString(p.s.popString())
-	case 'c':
-		p.buf.WriteByte(p.s.popByte())
+	case 'o', 'd', 'x', 'X', 's', 'c':
+		p.format("", ch)
 	case 'p':
 		p.pos++
 		return p.pushParamFn
@@ -156,6 +155,86 @@ func (p *parametizer) scanCodeFn() stateFn {
 	case '{':
 		p.pos++
 		return p.pushIntfn
+	case 'l', '+', '-', '*', '/', 'm', '&', '|', '^', '=', '>', '<', 'A', 'O',
+		'!', '~', 'r', 'i':
+		p.apply(ch)
+	case '?', ';':
+	case 't':
+		return p.scanThenFn
+	case 'e':
+		p.skipElse = true
+		return p.skipTextFn
+	}
+	p.pos++
+	return p.scanTextFn
+}
+
+func (p *parametizer) scanFormatFn() stateFn {
+	// start is the first flag, width or precision char, following the '%' or
+	// the "%:"
+	start := p.pos
+	for {
+		ch, err := p.peek()
+		if err != nil {
+			return nil
+		}
+		switch {
+		case bytes.IndexByte([]byte("oxXsdc"), ch) != -1:
+			f := ""
+			if p.pos > start {
+				f = "%" + string(p.z[start:p.pos+1])
+			}
+			p.format(f, ch)
+			p.pos++
+			return p.scanTextFn
+		case bytes.IndexByte([]byte("-+# .0123456789"), ch) == -1:
+			// malformed, so write the sequence as text, and scan the char
+			// as text
+			end := start - 1
+			if p.z[end] == ':' {
+				end--
+			}
+			p.buf.Write(p.z[end:p.pos])
+			return p.scanTextFn
+		}
+		p.pos++
+	}
+}
+
+// format pops a value from the stack and writes it to the buffer with the
+// conversion verb. When f is not empty, it is used as the (printf style)
+// format.
+func (p *parametizer) format(f string, verb byte) {
+	if f != "" {
+		switch verb {
+		case 'o', 'd', 'x', 'X':
+			fmt.Fprintf(p.buf, f, p.s.popInt())
+		case 's':
+			fmt.Fprintf(p.buf, f, p.s.popString())
+		case 'c':
+			fmt.Fprintf(p.buf, f, p.s.popByte())
+		}
+		return
+	}
+	switch verb {
+	case 'o':
+		p.buf.WriteString(strconv.FormatInt(int64(p.s.popInt()), 8))
+	case 'd':
+		p.buf.WriteString(strconv.Itoa(p.s.popInt()))
+	case 'x':
+		p.buf.WriteString(strconv.FormatInt(int64(p.s.popInt()), 16))
+	case 'X':
+		p.buf.WriteString(strings.ToUpper(strconv.FormatInt(int64(p.s.popInt()), 16)))
+	case 's':
+		p.buf.WriteString(p.s.popString())
+	case 'c':
+		p.buf.WriteByte(p.s.popByte())
+	}
+}
+
+// apply applies the operator op to the stack or parameters.
+func (p *parametizer) apply(op byte) {
+	switch op {
 	case 'l':
 		p.s.push(len(p.s.popString()))
 	case '+':
@@ -209,50 +288,16 @@ func (p *parametizer) scanCodeFn() stateFn {
 		p.s.push(!p.s.popBool())
 	case '~':
 		p.s.push(^p.s.popInt())
+	case 'r':
+		// termcap style reversal of the first two parameters
+		p.params[0], p.params[1] = p.params[1], p.params[0]
 	case 'i':
 		for i := range p.params[:2] {
 			if n, ok := p.params[i].(int); ok {
 				p.params[i] = n + 1
 			}
 		}
-	case '?', ';':
-	case 't':
-		return p.scanThenFn
-	case 'e':
-		p.skipElse = true
-		return p.skipTextFn
-	}
-	p.pos++
-	return p.scanTextFn
-}
-
-func (p *parametizer) scanFormatFn() stateFn {
-	// the character was already read, so no need to check the error.
-	ch, _ := p.peek()
-	// 6 should be the maximum length of a format string, for example "%:-9.9d".
-	f := []byte{'%', ch, 0, 0, 0, 0}
-	var err error
-	for {
-		p.pos++
-		ch, err = p.peek()
-		if err != nil {
-			return nil
-		}
-		f = append(f, ch)
-		switch ch {
-		case 'o', 'd', 'x', 'X':
-			fmt.Fprintf(p.buf, string(f), p.s.popInt())
-			break
-		case 's':
-			fmt.Fprintf(p.buf, string(f), p.s.popString())
-			break
-		case 'c':
-			fmt.Fprintf(p.buf, string(f), p.s.popByte())
-			break
-		}
 	}
-	p.pos++
-	return p.scanTextFn
 }
 
 func (p *parametizer) pushParamFn() stateFn {
@@ -275,13 +320,7 @@ func (p *parametizer) setDsVarFn() stateFn {
 	if err != nil {
 		return nil
 	}
-	if ch >= 'A' && ch <= 'Z' {
-		staticVars.Lock()
-		staticVars.vars[int(ch-'A')] = p.s.pop()
-		staticVars.Unlock()
-	} else if ch >= 'a' && ch <= 'z' {
-		p.vars[int(ch-'a')] = p.s.pop()
-	}
+	p.setVar(ch)
 	p.pos++
 	return p.scanTextFn
 }
@@ -291,17 +330,34 @@ func (p *parametizer) getDsVarFn() stateFn {
 	if err != nil {
 		return nil
 	}
-	var a byte
+	p.getVar(ch)
+	p.pos++
+	return p.scanTextFn
+}
+
+// setVar pops a value from the stack, setting the static (A-Z) or dynamic
+// (a-z) variable ch.
+func (p *parametizer) setVar(ch byte) {
 	if ch >= 'A' && ch <= 'Z' {
-		a = 'A'
+		p.ctx.Lock()
+		p.ctx.vars[int(ch-'A')] = p.s.pop()
+		p.ctx.Unlock()
 	} else if ch >= 'a' && ch <= 'z' {
-		a = 'a'
+		p.vars[int(ch-'a')] = p.s.pop()
+	}
+}
+
+// getVar pushes the static (A-Z) or dynamic (a-z) variable ch to the stack.
+func (p *parametizer) getVar(ch byte) {
+	if ch >= 'A' && ch <= 'Z' {
+		p.ctx.Lock()
+		p.s.push(p.ctx.vars[int(ch-'A')])
+		p.ctx.Unlock()
+	} else if ch >= 'a' && ch <= 'z' {
+		p.s.push(p.vars[int(ch-'a')])
+	} else {
+		p.s.push(0)
 	}
-	staticVars.Lock()
-	p.s.push(staticVars.vars[int(ch-a)])
-	staticVars.Unlock()
-	p.pos++
-	return p.scanTextFn
 }
 
 func (p *parametizer) pushIntfn() stateFn {
@@ -387,8 +443,26 @@ func (p *parametizer) skipElseFn() stateFn {
 }
 
 // Printf evaluates a parameterized terminfo value z, interpolating params.
+// Static variables are shared by all calls to Printf and Fprintf; use a
+// ParamContext to keep them separate.
+//
+// Supports the operators described in terminfo(5), as well as the termcap %r
+// operator (reversing the first two parameters) found in some translated
+// termcap entries.
 func Printf(z []byte, params ...interface{}) string {
-	p := newParametizer(z)
+	return staticVars.Printf(z, params...)
+}
+
+// Fprintf evaluates a parameterized terminfo value z, interpolating params and
+// writing to w.
+func Fprintf(w io.Writer, z []byte, params ...interface{}) {
+	w.Write([]byte(Printf(z, params...)))
+}
+
+// Printf evaluates a parameterized terminfo value z, interpolating params,
+// and reading and setting the static variables of the context.
+func (ctx *ParamContext) Printf(z []byte, params ...interface{}) string {
+	p := newParametizer(ctx, z)
 	defer p.reset()
 	// make sure we always have 9 parameters -- makes it easier
 	// later to skip checks and its faster
@@ -399,7 +473,154 @@ func Printf(z []byte, params ...interface{}) string {
 }
 
 // Fprintf evaluates a parameterized terminfo value z, interpolating params and
-// writing to w.
-func Fprintf(w io.Writer, z []byte, params ...interface{}) {
-	w.Write([]byte(Printf(z, params...)))
+// writing to w, using the static variables of the context.
+func (ctx *ParamContext) Fprintf(w io.Writer, z []byte, params ...interface{}) {
+	w.Write([]byte(ctx.Printf(z, params...)))
+}
+
+// ParamError is a malformed parameterized string error.
+type ParamError struct {
+	// Cap is the name of the string capability, if known.
+	Cap string
+	// Seq is the offending sequence.
+	Seq string
+	// Pos is the position of the sequence.
+	Pos int
+}
+
+// Error satisfies the error interface.
+func (err *ParamError) Error() string {
+	s := fmt.Sprintf("invalid sequence %q at position %d", err.Seq, err.Pos)
+	if err.Cap != "" {
+		return err.Cap + ": " + s
+	}
+	return s
+}
+
+// newParamError creates a param error for the sequence in z from start to end.
+func newParamError(z []byte, start, end int) *ParamError {
+	if end > len(z) {
+		end = len(z)
+	}
+	return &ParamError{
+		Seq: string(z[start:end]),
+		Pos: start,
+	}
+}
+
+// analyzeParams scans the parameterized terminfo value z, returning the
+// highest parameter referenced by z, or an error if z is malformed.
+func analyzeParams(z []byte) (int, error) {
+	var n, nest int
+	for i := 0; i < len(z); i++ {
+		if z[i] != '%' {
+			continue
+		}
+		start := i
+		if i++; i >= len(z) {
+			return 0, newParamError(z, start, i)
+		}
+		switch z[i] {
+		case '%', 'c', 'd', 'o', 'x', 'X', 's', 'l', '+', '-', '*', '/', 'm',
+			'&', '|', '^', '=', '>', '<', 'A', 'O', '!', '~', 'i', 'r':
+		case 'p':
+			if i++; i >= len(z) {
+				return 0, newParamError(z, start, i)
+			}
+			// terminfo only defines parameters 1 through 9
+			ch := z[i]
+			if ch < '1' || ch > '9' {
+				return 0, newParamError(z, start, i+1)
+			}
+			if int(ch-'0') > n {
+				n = int(ch - '0')
+			}
+		case 'P', 'g':
+			if i++; i >= len(z) || !(z[i] >= 'a' && z[i] <= 'z' || z[i] >= 'A' && z[i] <= 'Z') {
+				return 0, newParamError(z, start, i+1)
+			}
+		case '\'':
+			if i+2 >= len(z) || z[i+2] != '\'' {
+				return 0, newParamError(z, start, i+3)
+			}
+			i += 2
+		case '{':
+			j := bytes.IndexByte(z[i:], '}')
+			if j == -1 {
+				return 0, newParamError(z, start, len(z))
+			}
+			// integer constants are one or more decimal digits
+			if j == 1 || bytes.IndexFunc(z[i+1:i+j], func(r rune) bool { return r < '0' || r > '9' }) != -1 {
+				return 0, newParamError(z, start, i+j+1)
+			}
+			i += j
+		case '?':
+			nest++
+		case 't', 'e':
+			if nest == 0 {
+				return 0, newParamError(z, start, i+1)
+			}
+		case ';':
+			if nest == 0 {
+				return 0, newParamError(z, start, i+1)
+			}
+			nest--
+		case ':', '#', ' ', '.', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			// format spec, ie %:-9.9d
+			if z[i] == ':' {
+				i++
+			}
+			for ; i < len(z) && bytes.IndexByte([]byte("doxXsc"), z[i]) == -1; i++ {
+				if bytes.IndexByte([]byte("-+# .0123456789"), z[i]) == -1 {
+					return 0, newParamError(z, start, i+1)
+				}
+			}
+			if i >= len(z) {
+				return 0, newParamError(z, start, i)
+			}
+		default:
+			return 0, newParamError(z, start, i+1)
+		}
+	}
+	if nest != 0 {
+		return 0, newParamError(z, len(z), len(z))
+	}
+	return n, nil
+}
+
+// NeedsParams determines if the parameterized terminfo value z references any
+// parameters. Values that do not can be evaluated once with Printf (as they may
+// still contain other % sequences, such as %%) and the result reused.
+func NeedsParams(z []byte) bool {
+	n, err := analyzeParams(z)
+	return err == nil && n > 0
+}
+
+// ValidateAll validates the parameterized values of all string and extended
+// string caps, returning a *ParamError for each malformed value. An acs_chars
+// cap with an unpaired char is reported as ErrInvalidACSChars (see ACSValid).
+func (ti *Terminfo) ValidateAll() []error {
+	var errs []error
+	if ti.Strings[AcsChars] != nil && !ti.ACSValid() {
+		errs = append(errs, ErrInvalidACSChars)
+	}
+	validate := func(name string, z []byte) {
+		if _, err := analyzeParams(z); err != nil {
+			err.(*ParamError).Cap = name
+			errs = append(errs, err)
+		}
+	}
+	for i := 0; i < CapCountString; i++ {
+		// acs_chars is a character map, and user6 and user8 are scanf style
+		// response formats, so they are not parameterized values
+		if z := ti.Strings[i]; z != nil && i != AcsChars && i != User6 && i != User8 {
+			validate(StringCapName(i), z)
+		}
+	}
+	for i := 0; i < len(ti.ExtStringNames); i++ {
+		if z, ok := ti.ExtStrings[i]; ok {
+			validate(ti.ExtStringName(i), z)
+		}
+	}
+	return errs
 }
@@ -0,0 +1,223 @@
+package terminfo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// EscapeCap escapes the string cap value v into the terminfo source format,
+// as written by infocmp (ie, \E for escape, ^X for control characters, and
+// \nnn octal for bytes that cannot otherwise be represented, such as ',').
+//
+// see _nc_tic_expand in ncurses-6.0/ncurses/tinfo/comp_expand.c
+func EscapeCap(v []byte) string {
+	length := len(v)
+	if length == 0 {
+		return ""
+	}
+	var s []byte
+	islong := length > 3
+	for i := 0; i < length; i++ {
+		ch, next := v[i], peek(v, i+1, length)
+		switch {
+		case ch == '%' && realprint(next) && next != ',' && next != '\\':
+			s = append(s, v[i], v[i+1])
+			i++
+		case ch == 128:
+			s = append(s, '\\', '0')
+		case ch == '\033':
+			s = append(s, '\\', 'E')
+		case ch == '\\':
+			s = append(s, '\\', '\\')
+		case realprint(ch) && ch != ',' && ch != ':' && ch != '!' && ch != '^':
+			s = append(s, ch)
+		case ch == '\r' && (islong || (i == length-1 && length > 2)):
+			s = append(s, '\\', 'r')
+		case ch == '\n' && islong:
+			s = append(s, '\\', 'n')
+		case realctl(ch) && ch != '\\' && (!islong || isdigit(peek(v, i+1, length))):
+			s = append(s, '^', ch+'@')
+		default:
+			s = append(s, []byte(fmt.Sprintf("\\%03o", ch))...)
+		}
+	}
+	return string(s)
+}
+
+// DiffSource writes a terminfo source fragment to w that defines b as a
+// variation of a, listing the caps of b that differ from a, cancelling (@)
+// the caps of a that b does not have, and ending with use=a.
+func DiffSource(a, b *Terminfo, w io.Writer) error {
+	if len(a.Names) == 0 || len(b.Names) == 0 {
+		return ErrEmptyTermName
+	}
+	buf := new(bytes.Buffer)
+	x, y := a.sourceCaps(), b.sourceCaps()
+	var diff [3]map[string]string
+	for i := range y {
+		diff[i] = make(map[string]string)
+		for name, v := range y[i] {
+			if z, ok := x[i][name]; !ok || z != v {
+				diff[i][name] = v
+			}
+		}
+		for name := range x[i] {
+			if _, ok := y[i][name]; !ok {
+				diff[i][name] = name + "@"
+			}
+		}
+	}
+	writeSource(buf, b, diff)
+	fmt.Fprintf(buf, "\tuse=%s,\n", a.Names[0])
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// SameCaps determines if a and b have the same caps (including extended
+// caps), ignoring their names. Entries with the same caps are candidates for
+// being aliases of each other.
+func SameCaps(a, b *Terminfo) bool {
+	return reflect.DeepEqual(a.sourceCaps(), b.sourceCaps())
+}
+
+// TicCheck checks ti with the ncurses terminfo compiler (tic -c), returning
+// its diagnostics, such as caps with the wrong number of parameters. The
+// entry is written in terminfo source format to a temporary file, and the
+// file name is stripped from the diagnostics. Returns ErrTicNotFound when tic
+// is not installed.
+func (ti *Terminfo) TicCheck() ([]string, error) {
+	if len(ti.Names) == 0 {
+		return nil, ErrEmptyTermName
+	}
+	tic, err := exec.LookPath("tic")
+	if err != nil {
+		return nil, ErrTicNotFound
+	}
+	f, err := os.CreateTemp("", "terminfo")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	buf := new(bytes.Buffer)
+	writeSource(buf, ti, ti.sourceCaps())
+	_, err = f.Write(buf.Bytes())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	out, err := exec.Command(tic, "-x", "-c", "-v1", f.Name()).CombinedOutput()
+	// diagnostics are prefixed with the quoted file name, other lines are
+	// informational or fatal errors
+	prefix := strconv.Quote(f.Name()) + ", "
+	var diags []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, prefix) {
+			diags = append(diags, strings.TrimPrefix(line, prefix))
+		}
+	}
+	if err != nil && len(diags) == 0 {
+		return nil, fmt.Errorf("tic: %v: %s", err, bytes.TrimSpace(out))
+	}
+	return diags, nil
+}
+
+// writeSource writes the names line of ti and the bool, num and string caps
+// (as returned by sourceCaps) in terminfo source format, with the caps of each
+// family sorted by name.
+func writeSource(w io.Writer, ti *Terminfo, caps [3]map[string]string) {
+	names := ti.Names
+	if ti.LongName != "" {
+		names = append(names[:len(names):len(names)], ti.LongName)
+	}
+	fmt.Fprintf(w, "%s,\n", strings.Join(names, "|"))
+	for _, m := range caps {
+		keys := make([]string, 0, len(m))
+		for name := range m {
+			keys = append(keys, name)
+		}
+		sort.Strings(keys)
+		for _, name := range keys {
+			fmt.Fprintf(w, "\t%s,\n", m[name])
+		}
+	}
+}
+
+// sourceCaps returns the present bool, num and string caps (including
+// extended caps) of ti in terminfo source format, keyed by the cap's short
+// name.
+func (ti *Terminfo) sourceCaps() [3]map[string]string {
+	caps := [3]map[string]string{
+		make(map[string]string),
+		make(map[string]string),
+		make(map[string]string),
+	}
+	for k, v := range ti.Bools {
+		if v {
+			caps[CapFamilyBool][BoolCapNameShort(k)] = BoolCapNameShort(k)
+		}
+	}
+	for k, v := range ti.ExtBools {
+		if v {
+			caps[CapFamilyBool][ti.ExtBoolName(k)] = ti.ExtBoolName(k)
+		}
+	}
+	for k, v := range ti.Nums {
+		if v >= 0 {
+			caps[CapFamilyNum][NumCapNameShort(k)] = NumCapNameShort(k) + "#" + strconv.Itoa(v)
+		}
+	}
+	for k, v := range ti.ExtNums {
+		if v >= 0 {
+			caps[CapFamilyNum][ti.ExtNumName(k)] = ti.ExtNumName(k) + "#" + strconv.Itoa(v)
+		}
+	}
+	for k, v := range ti.Strings {
+		if v != nil {
+			caps[CapFamilyString][StringCapNameShort(k)] = StringCapNameShort(k) + "=" + EscapeCap(v)
+		}
+	}
+	for k, v := range ti.ExtStrings {
+		if v != nil {
+			caps[CapFamilyString][ti.ExtStringName(k)] = ti.ExtStringName(k) + "=" + EscapeCap(v)
+		}
+	}
+	return caps
+}
+
+// peek peeks a byte.
+func peek(b []byte, pos, length int) byte {
+	if pos < length {
+		return b[pos]
+	}
+	return 0
+}
+
+func isprint(b byte) bool {
+	return unicode.IsPrint(rune(b))
+}
+
+func realprint(b byte) bool {
+	return b < 127 && isprint(b)
+}
+
+func iscntrl(b byte) bool {
+	return unicode.IsControl(rune(b))
+}
+
+func realctl(b byte) bool {
+	return b < 127 && iscntrl(b)
+}
+
+func isdigit(b byte) bool {
+	return unicode.IsDigit(rune(b))
+}
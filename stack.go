@@ -15,23 +15,46 @@ func (s *stack) pop() interface{} {
 	return v
 }
 
+// popInt pops an int. As with ncurses, chars (%'c') are their char code and
+// bools (from comparisons) are 1 or 0.
 func (s *stack) popInt() int {
-	if i, ok := s.pop().(int); ok {
-		return i
+	switch v := s.pop().(type) {
+	case int:
+		return v
+	case byte:
+		return int(v)
+	case bool:
+		if v {
+			return 1
+		}
 	}
 	return 0
 }
 
+// popBool pops a bool. Ints and chars are true when non-zero.
 func (s *stack) popBool() bool {
-	if b, ok := s.pop().(bool); ok {
-		return b
+	switch v := s.pop().(type) {
+	case bool:
+		return v
+	case int:
+		return v != 0
+	case byte:
+		return v != 0
 	}
 	return false
 }
 
+// popByte pops a char. Ints are truncated to their low byte.
 func (s *stack) popByte() byte {
-	if b, ok := s.pop().(byte); ok {
-		return b
+	switch v := s.pop().(type) {
+	case byte:
+		return v
+	case int:
+		return byte(v)
+	case bool:
+		if v {
+			return 1
+		}
 	}
 	return 0
 }
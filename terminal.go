@@ -0,0 +1,124 @@
+package terminfo
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Terminal is a simple terminal driver, that writes the sequences for a
+// terminal's capabilities to a buffered writer.
+type Terminal struct {
+	ti *Terminfo
+	w  *bufio.Writer
+}
+
+// NewTerminal creates a terminal driver for the terminal ti, writing to w.
+// Output is buffered, and is only written to w on Flush.
+func NewTerminal(ti *Terminfo, w io.Writer) *Terminal {
+	return &Terminal{
+		ti: ti,
+		w:  bufio.NewWriter(w),
+	}
+}
+
+// Write satisfies the io.Writer interface.
+func (t *Terminal) Write(p []byte) (int, error) {
+	return t.w.Write(p)
+}
+
+// Flush writes any buffered output to the underlying writer.
+func (t *Terminal) Flush() error {
+	return t.w.Flush()
+}
+
+// MoveTo moves the cursor to col, row. The origin 0, 0 is in the upper left
+// corner of the screen. Returns ErrNegativeCoordinate when col or row is
+// negative.
+func (t *Terminal) MoveTo(col, row int) error {
+	s, err := t.ti.GotoChecked(row, col)
+	if err != nil {
+		return err
+	}
+	return t.puts(s)
+}
+
+// SetFg sets the foreground color.
+func (t *Terminal) SetFg(color int) error {
+	return t.puts(t.ti.Printf(SetAForeground, color))
+}
+
+// SetBg sets the background color.
+func (t *Terminal) SetBg(color int) error {
+	return t.puts(t.ti.Printf(SetABackground, color))
+}
+
+// ResetAttrs turns off all attributes, including colors.
+func (t *Terminal) ResetAttrs() error {
+	return t.puts(t.ti.Printf(ExitAttributeMode))
+}
+
+// Clear clears the screen and moves the cursor to the upper left corner.
+func (t *Terminal) Clear() error {
+	return t.puts(t.ti.Printf(ClearScreen))
+}
+
+// HideCursor makes the cursor invisible.
+func (t *Terminal) HideCursor() error {
+	return t.puts(t.ti.Printf(CursorInvisible))
+}
+
+// ShowCursor makes the cursor visible.
+func (t *Terminal) ShowCursor() error {
+	return t.puts(t.ti.Printf(CursorNormal))
+}
+
+// puts writes s, applying the padding (of the form $<[delay]>, where delay is
+// in msec) it contains. Padding is applied by flushing the output and waiting
+// for the delay, and is skipped when the terminal uses xon/xoff handshaking,
+// unless the padding is mandatory ($<[delay]/>).
+func (t *Terminal) puts(s string) error {
+	z := []byte(s)
+	for {
+		start := bytes.Index(z, []byte("$<"))
+		if start == -1 {
+			break
+		}
+		end := bytes.IndexByte(z[start:], '>')
+		if end == -1 {
+			break
+		}
+		if _, err := t.w.Write(z[:start]); err != nil {
+			return err
+		}
+		delay, mandatory := parseDelay(z[start+2 : start+end])
+		z = z[start+end+1:]
+		if delay == 0 || (t.ti.Bools[XonXoff] && !mandatory) {
+			continue
+		}
+		if err := t.w.Flush(); err != nil {
+			return err
+		}
+		time.Sleep(delay)
+	}
+	_, err := t.w.Write(z)
+	return err
+}
+
+// parseDelay parses a padding specification (ie, 5, 5.5*, 10/), returning the
+// delay and whether the padding is mandatory. The proportional (*) flag is
+// treated as affecting a single line.
+func parseDelay(spec []byte) (time.Duration, bool) {
+	var mandatory bool
+	for len(spec) != 0 && (spec[len(spec)-1] == '*' || spec[len(spec)-1] == '/') {
+		mandatory = mandatory || spec[len(spec)-1] == '/'
+		spec = spec[:len(spec)-1]
+	}
+	ms, err := strconv.ParseFloat(string(spec), 64)
+	if err != nil {
+		return 0, mandatory
+	}
+	return time.Duration(ms * float64(time.Millisecond)), mandatory
+}
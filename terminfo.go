@@ -7,6 +7,7 @@ import (
 	"io"
 	"io/ioutil"
 	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
@@ -42,14 +43,58 @@ const (
 	ErrFileNotFound Error = "file not found"
 	// ErrInvalidTermProgramVersion is the invalid TERM_PROGRAM_VERSION error.
 	ErrInvalidTermProgramVersion Error = "invalid TERM_PROGRAM_VERSION"
+	// ErrNotTerminal is the not a terminal error.
+	ErrNotTerminal Error = "not a terminal"
+	// ErrNoStatusLine is the no status line error.
+	ErrNoStatusLine Error = "no status line"
+	// ErrNegativeCoordinate is the negative coordinate error.
+	ErrNegativeCoordinate Error = "negative coordinate"
+	// ErrTicNotFound is the tic not found error.
+	ErrTicNotFound Error = "tic not found"
+	// ErrInvalidCapIndex is the invalid cap index error.
+	ErrInvalidCapIndex Error = "invalid cap index"
+	// ErrInvalidACSChars is the invalid acs chars error.
+	ErrInvalidACSChars Error = "invalid acs chars"
 )
 
+// HeaderError is an invalid header field error.
+type HeaderError struct {
+	// Err is ErrInvalidHeader or ErrInvalidExtendedHeader.
+	Err Error
+	// Field is the name of the invalid field.
+	Field string
+	// Value is the value of the field.
+	Value int
+}
+
+// Error satisfies the error interface.
+func (err *HeaderError) Error() string {
+	return string(err.Err) + ": " + err.Field + " " + strconv.Itoa(err.Value)
+}
+
+// Unwrap returns the underlying error.
+func (err *HeaderError) Unwrap() error {
+	return err.Err
+}
+
 // Terminfo describes a terminal's capabilities.
 type Terminfo struct {
 	// File is the original source file.
 	File string
-	// Names are the provided cap names.
+	// CanonicalName is the name of the entry File resolves to, which differs
+	// from the base name of File when File is a symlink to another entry (ie,
+	// xterm-debian is a symlink to xterm).
+	CanonicalName string
+	// Unaligned is whether the file was decoded without the alignment bytes
+	// written by ncurses, as is done by some non-ncurses compilers.
+	Unaligned bool
+	// BigEndian is whether the file was stored big endian.
+	BigEndian bool
+	// Names are the provided cap names (the terminal name and its aliases).
 	Names []string
+	// LongName is the verbose description of the terminal (the last field of
+	// the names section).
+	LongName string
 	// Bools are the bool capabilities.
 	Bools map[int]bool
 	// BoolsM are the missing bool capabilities.
@@ -74,23 +119,48 @@ type Terminfo struct {
 	ExtStrings map[int][]byte
 	// ExtStringsNames is the map of extended string capabilities to their index.
 	ExtStringNames map[int][]byte
+	// table is the raw string table.
+	table []byte
 }
 
 // Decode decodes the terminfo data contained in buf.
 func Decode(buf []byte) (*Terminfo, error) {
+	ti, misaligned, err := decode(buf, false)
+	if err != nil {
+		// some non-ncurses compilers do not write the alignment byte
+		// following the bools, so retry without it when that is the likely
+		// cause of the error
+		if misaligned {
+			if z, _, zerr := decode(buf, true); zerr == nil {
+				z.Unaligned = true
+				return z, nil
+			}
+		}
+		return nil, err
+	}
+	return ti, nil
+}
+
+// decode decodes the terminfo data contained in buf, optionally without the
+// alignment bytes. When decoding fails, reports whether the error occurred
+// in the num or string sections following an alignment byte, and so may be
+// caused by the file not having the alignment bytes.
+func decode(buf []byte, unaligned bool) (*Terminfo, bool, error) {
 	var err error
 	// check max file length
-	if len(buf) >= maxFileLength {
-		return nil, ErrInvalidFileSize
+	if len(buf) > maxFileLength {
+		return nil, false, ErrInvalidFileSize
 	}
 	d := &decoder{
-		buf: buf,
-		n:   len(buf),
+		buf:       buf,
+		n:         len(buf),
+		unaligned: unaligned,
+		bigEndian: isBigEndian(buf),
 	}
 	// read header
 	h, err := d.readInts(6, 16)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	var numWidth int
 	// check magic
@@ -100,118 +170,124 @@ func Decode(buf []byte) (*Terminfo, error) {
 	case h[fieldMagic] == magicExtended:
 		numWidth = 32
 	default:
-		return nil, ErrInvalidMagic
+		return nil, false, ErrInvalidMagic
 	}
 	// check header
-	if hasInvalidCaps(h) {
-		return nil, ErrInvalidHeader
+	if err := checkHeader(h); err != nil {
+		return nil, false, err
 	}
+	// misaligned is whether an error reading the num and string sections
+	// may be caused by a missing alignment byte following the bools
+	misaligned := !unaligned && d.pad(h[fieldNameSize]+h[fieldBoolCount]) == 1
 	// check remaining length
-	if d.n-d.pos < capLength(h) {
-		return nil, ErrUnexpectedFileEnd
+	if d.n-d.pos < d.capLength(h) {
+		return nil, misaligned, ErrUnexpectedFileEnd
 	}
 	// read names
 	names, err := d.readBytes(h[fieldNameSize])
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	// check name is terminated properly
 	i := findNull(names, 0)
 	if i == -1 {
-		return nil, ErrInvalidNames
+		return nil, false, ErrInvalidNames
 	}
 	names = names[:i]
 	// read bool caps
 	bools, boolsM, err := d.readBools(h[fieldBoolCount])
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	// read num caps
 	nums, numsM, err := d.readNums(h[fieldNumCount], numWidth)
 	if err != nil {
-		return nil, err
+		return nil, misaligned, err
 	}
 	// read string caps
-	strs, strsM, err := d.readStrings(h[fieldStringCount], h[fieldTableSize])
+	strs, strsM, table, err := d.readStrings(h[fieldStringCount], h[fieldTableSize])
 	if err != nil {
-		return nil, err
+		return nil, misaligned, err
 	}
 	ti := &Terminfo{
-		Names:    strings.Split(string(names), "|"),
-		Bools:    bools,
-		BoolsM:   boolsM,
-		Nums:     nums,
-		NumsM:    numsM,
-		Strings:  strs,
-		StringsM: strsM,
-	}
-	// at the end of file, so no extended caps
-	if d.pos >= d.n {
-		return ti, nil
+		BigEndian: d.bigEndian,
+		Names:     strings.Split(string(names), "|"),
+		Bools:     bools,
+		BoolsM:    boolsM,
+		Nums:      nums,
+		NumsM:     numsM,
+		Strings:   strs,
+		StringsM:  strsM,
+		table:     table,
+	}
+	// split long name from the names
+	if n := len(ti.Names); n > 1 {
+		ti.Names, ti.LongName = ti.Names[:n-1:n-1], ti.Names[n-1]
+	}
+	// at the end of file (ignoring any trailing bytes), so no extended caps
+	if d.n-d.pos < 10 {
+		return ti, false, nil
 	}
 	// decode extended header
 	eh, err := d.readInts(5, 16)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
-	// check extended offset field
-	if hasInvalidExtOffset(eh) {
-		return nil, ErrInvalidExtendedHeader
+	// check extended header
+	if err := checkExtHeader(eh); err != nil {
+		return nil, false, err
 	}
-	// check extended cap lengths
-	if d.n-d.pos != extCapLength(eh, numWidth) {
-		return nil, ErrInvalidExtendedHeader
+	// check extended cap lengths (bytes following the extended caps are
+	// ignored)
+	if d.n-d.pos < d.extCapLength(eh, numWidth) {
+		return nil, false, ErrUnexpectedFileEnd
 	}
 	// read extended bool caps
 	ti.ExtBools, _, err = d.readBools(eh[fieldExtBoolCount])
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	// read extended num caps
 	ti.ExtNums, _, err = d.readNums(eh[fieldExtNumCount], numWidth)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	// read extended string data table indexes
-	extIndexes, err := d.readInts(eh[fieldExtOffsetCount], 16)
+	extIndexes, err := d.readInts(extOffsetCount(eh), 16)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	// read string data table
 	extData, err := d.readBytes(eh[fieldExtTableSize])
 	if err != nil {
-		return nil, err
-	}
-	// precautionary check that exactly at end of file
-	if d.pos != d.n {
-		return nil, ErrUnexpectedFileEnd
+		return nil, false, err
 	}
 	var last int
 	// read extended string caps
 	ti.ExtStrings, last, err = readStrings(extIndexes, extData, eh[fieldExtStringCount])
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	extIndexes, extData = extIndexes[eh[fieldExtStringCount]:], extData[last:]
 	// read extended bool names
 	ti.ExtBoolNames, _, err = readStrings(extIndexes, extData, eh[fieldExtBoolCount])
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	extIndexes = extIndexes[eh[fieldExtBoolCount]:]
 	// read extended num names
 	ti.ExtNumNames, _, err = readStrings(extIndexes, extData, eh[fieldExtNumCount])
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	extIndexes = extIndexes[eh[fieldExtNumCount]:]
 	// read extended string names
 	ti.ExtStringNames, _, err = readStrings(extIndexes, extData, eh[fieldExtStringCount])
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	// extIndexes = extIndexes[eh[fieldExtStringCount]:]
-	return ti, nil
+	return ti, false, nil
 }
 
 // Open reads the terminfo file name from the specified directory dir.
@@ -238,7 +314,10 @@ func Open(dir, name string) (*Terminfo, error) {
 		return nil, err
 	}
 	// save original file name
-	ti.File = filename
+	ti.File, ti.CanonicalName = filename, path.Base(filename)
+	if f, err := filepath.EvalSymlinks(filename); err == nil {
+		ti.CanonicalName = filepath.Base(f)
+	}
 	// add to cache
 	termCache.Lock()
 	for _, n := range ti.Names {
@@ -258,7 +337,7 @@ func (ti *Terminfo) boolCaps(f func(int) string, extended bool) map[string]bool
 		}
 	} else {
 		for k, v := range ti.ExtBools {
-			m[string(ti.ExtBoolNames[k])] = v
+			m[ti.ExtBoolName(k)] = v
 		}
 	}
 	return m
@@ -296,7 +375,7 @@ func (ti *Terminfo) numCaps(f func(int) string, extended bool) map[string]int {
 		}
 	} else {
 		for k, v := range ti.ExtNums {
-			m[string(ti.ExtNumNames[k])] = v
+			m[ti.ExtNumName(k)] = v
 		}
 	}
 	return m
@@ -334,7 +413,7 @@ func (ti *Terminfo) stringCaps(f func(int) string, extended bool) map[string][]b
 		}
 	} else {
 		for k, v := range ti.ExtStrings {
-			m[string(ti.ExtStringNames[k])] = v
+			m[ti.ExtStringName(k)] = v
 		}
 	}
 	return m
@@ -362,15 +441,191 @@ func (ti *Terminfo) ExtStringCapsShort() map[string][]byte {
 	return ti.stringCaps(StringCapNameShort, true)
 }
 
+// Value is a capability value.
+type Value struct {
+	// Family is the capability family.
+	Family CapFamily
+	// Extended is whether the capability is an extended capability.
+	Extended bool
+	// Bool is the value of a bool capability.
+	Bool bool
+	// Num is the value of a num capability.
+	Num int
+	// String is the value of a string capability.
+	String []byte
+}
+
+// Flatten returns all present capabilities in a single map, using the long
+// name as the key for standard capabilities, and the cap name for extended
+// capabilities.
+func (ti *Terminfo) Flatten() map[string]Value {
+	m := make(map[string]Value)
+	for k, v := range ti.Bools {
+		if v {
+			m[BoolCapName(k)] = Value{Family: CapFamilyBool, Bool: v}
+		}
+	}
+	for k, v := range ti.Nums {
+		if v >= 0 {
+			m[NumCapName(k)] = Value{Family: CapFamilyNum, Num: v}
+		}
+	}
+	for k, v := range ti.Strings {
+		if v != nil {
+			m[StringCapName(k)] = Value{Family: CapFamilyString, String: v}
+		}
+	}
+	for k, v := range ti.ExtBools {
+		if v {
+			m[ti.ExtBoolName(k)] = Value{Family: CapFamilyBool, Extended: true, Bool: v}
+		}
+	}
+	for k, v := range ti.ExtNums {
+		if v >= 0 {
+			m[ti.ExtNumName(k)] = Value{Family: CapFamilyNum, Extended: true, Num: v}
+		}
+	}
+	for k, v := range ti.ExtStrings {
+		if v != nil {
+			m[ti.ExtStringName(k)] = Value{Family: CapFamilyString, Extended: true, String: v}
+		}
+	}
+	return m
+}
+
+// CapEntry is a present capability, as returned by Entries.
+type CapEntry struct {
+	// Name is the long name of a standard capability, or the name of an
+	// extended capability.
+	Name string
+	// Short is the short name of a standard capability. Extended capabilities
+	// only have a single name, so it is the same as Name.
+	Short string
+	// Value is the bool, int or []byte value of the capability.
+	Value interface{}
+	// Family is the capability family.
+	Family CapFamily
+	// Extended is whether the capability is an extended capability.
+	Extended bool
+}
+
+// Entries returns all present capabilities (the same as Flatten), ordered by
+// family (bool, num, string) and then index, with the standard capabilities
+// before the extended capabilities.
+func (ti *Terminfo) Entries() []CapEntry {
+	var entries []CapEntry
+	for i := 0; i < CapCountBool; i++ {
+		if ti.Bools[i] {
+			entries = append(entries, CapEntry{BoolCapName(i), BoolCapNameShort(i), true, CapFamilyBool, false})
+		}
+	}
+	for i := 0; i < CapCountNum; i++ {
+		if v, ok := ti.Nums[i]; ok && v >= 0 {
+			entries = append(entries, CapEntry{NumCapName(i), NumCapNameShort(i), v, CapFamilyNum, false})
+		}
+	}
+	for i := 0; i < CapCountString; i++ {
+		if v := ti.Strings[i]; v != nil {
+			entries = append(entries, CapEntry{StringCapName(i), StringCapNameShort(i), v, CapFamilyString, false})
+		}
+	}
+	for i := 0; i < len(ti.ExtBoolNames); i++ {
+		if ti.ExtBools[i] {
+			entries = append(entries, CapEntry{ti.ExtBoolName(i), ti.ExtBoolName(i), true, CapFamilyBool, true})
+		}
+	}
+	for i := 0; i < len(ti.ExtNumNames); i++ {
+		if v, ok := ti.ExtNums[i]; ok && v >= 0 {
+			entries = append(entries, CapEntry{ti.ExtNumName(i), ti.ExtNumName(i), v, CapFamilyNum, true})
+		}
+	}
+	for i := 0; i < len(ti.ExtStringNames); i++ {
+		if v := ti.ExtStrings[i]; v != nil {
+			entries = append(entries, CapEntry{ti.ExtStringName(i), ti.ExtStringName(i), v, CapFamilyString, true})
+		}
+	}
+	return entries
+}
+
+// ExtBoolName returns the name of the extended bool cap i, or an empty string if
+// there is no extended bool cap i.
+func (ti *Terminfo) ExtBoolName(i int) string {
+	return string(ti.ExtBoolNames[i])
+}
+
+// ExtNumName returns the name of the extended num cap i, or an empty string if
+// there is no extended num cap i.
+func (ti *Terminfo) ExtNumName(i int) string {
+	return string(ti.ExtNumNames[i])
+}
+
+// ExtStringName returns the name of the extended string cap i, or an empty
+// string if there is no extended string cap i.
+func (ti *Terminfo) ExtStringName(i int) string {
+	return string(ti.ExtStringNames[i])
+}
+
+// extIndex returns the index of the extended cap name in names.
+func extIndex(names map[int][]byte, name string) (int, bool) {
+	for i, n := range names {
+		if string(n) == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// ExtBool returns the value of the extended bool cap name, and whether the
+// cap is present.
+func (ti *Terminfo) ExtBool(name string) (bool, bool) {
+	i, ok := extIndex(ti.ExtBoolNames, name)
+	if !ok {
+		return false, false
+	}
+	v, ok := ti.ExtBools[i]
+	return v, ok
+}
+
+// ExtNum returns the value of the extended num cap name, and whether the cap
+// is present.
+func (ti *Terminfo) ExtNum(name string) (int, bool) {
+	i, ok := extIndex(ti.ExtNumNames, name)
+	if !ok {
+		return 0, false
+	}
+	v, ok := ti.ExtNums[i]
+	if !ok || v < 0 {
+		return 0, false
+	}
+	return v, true
+}
+
+// ExtString returns the value of the extended string cap name, and whether
+// the cap is present.
+func (ti *Terminfo) ExtString(name string) ([]byte, bool) {
+	i, ok := extIndex(ti.ExtStringNames, name)
+	if !ok {
+		return nil, false
+	}
+	v, ok := ti.ExtStrings[i]
+	return v, ok
+}
+
+// RawStringTable returns the raw string table of the decoded file, including
+// any unused bytes between the strings. Returns nil when ti was not decoded.
+func (ti *Terminfo) RawStringTable() []byte {
+	return ti.table
+}
+
 // Has determines if the bool cap i is present.
 func (ti *Terminfo) Has(i int) bool {
 	return ti.Bools[i]
 }
 
-// Num returns the num cap i, or -1 if not present.
+// Num returns the num cap i, or -1 if not present or cancelled.
 func (ti *Terminfo) Num(i int) int {
 	n, ok := ti.Nums[i]
-	if !ok {
+	if !ok || n < 0 {
 		return -1
 	}
 	return n
@@ -415,6 +670,38 @@ func (ti *Terminfo) Goto(row, col int) string {
 	return Printf(ti.Strings[CursorAddress], row, col)
 }
 
+// GotoChecked is the same as Goto, but returns ErrNegativeCoordinate when row
+// or col is negative, instead of a sequence addressing a garbage position.
+//
+// Coordinates are not clamped to the lines and cols caps, as those are only
+// the initial size of the terminal, and not the current window size.
+func (ti *Terminfo) GotoChecked(row, col int) (string, error) {
+	if row < 0 || col < 0 {
+		return "", ErrNegativeCoordinate
+	}
+	return ti.Goto(row, col), nil
+}
+
+// Reset returns the sequence that resets the terminal to sane modes, made up
+// of the reset_1string, reset_2string and reset_3string caps (in that order).
+// When a reset string is not defined, the matching init string
+// (init_1string, init_2string or init_3string) is used instead.
+func (ti *Terminfo) Reset() []byte {
+	var buf []byte
+	for _, i := range [][2]int{
+		{Reset1string, Init1string},
+		{Reset2string, Init2string},
+		{Reset3string, Init3string},
+	} {
+		if s, ok := ti.Strings[i[0]]; ok && s != nil {
+			buf = append(buf, s...)
+		} else {
+			buf = append(buf, ti.Strings[i[1]]...)
+		}
+	}
+	return buf
+}
+
 // Puts emits the string to the writer, but expands inline padding indications
 // (of the form $<[delay]> where [delay] is msec) to a suitable number of
 // padding characters (usually null bytes) based upon the supplied baud. At
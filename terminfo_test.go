@@ -1,8 +1,11 @@
 package terminfo
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -12,6 +15,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"golang.org/x/exp/maps"
 	"golang.org/x/exp/slices"
@@ -74,7 +78,14 @@ func TestValues(t *testing.T) {
 				t.Fatalf("expected no error, got: %v", err)
 			}
 			// check names
-			if !reflect.DeepEqual(ic.names, ti.Names) {
+			if n := len(ic.names); n > 1 {
+				if !reflect.DeepEqual(ic.names[:n-1], ti.Names) {
+					t.Errorf("names do not match")
+				}
+				if ic.names[n-1] != ti.LongName {
+					t.Errorf("long name should be %q, got: %q", ic.names[n-1], ti.LongName)
+				}
+			} else if !reflect.DeepEqual(ic.names, ti.Names) {
 				t.Errorf("names do not match")
 			}
 			// check bool caps
@@ -427,3 +438,2062 @@ func TestCapNames(t *testing.T) {
 		}
 	}
 }
+
+func TestIsObsolete(t *testing.T) {
+	for _, f := range []struct {
+		family CapFamily
+		count  int
+		short  func(int) string
+	}{
+		{CapFamilyBool, CapCountBool, BoolCapNameShort},
+		{CapFamilyNum, CapCountNum, NumCapNameShort},
+		{CapFamilyString, CapCountString, StringCapNameShort},
+	} {
+		for i := 0; i < f.count; i++ {
+			s := f.short(i)
+			if strings.HasPrefix(s, "OT") && !IsObsolete(f.family, i) {
+				t.Errorf("%s cap %d (%s) should be obsolete", f.family, i, s)
+			}
+		}
+	}
+	for _, i := range []int{MemoryLock, MemoryUnlock} {
+		if !IsObsolete(CapFamilyString, i) {
+			t.Errorf("string cap %d (%s) should be obsolete", i, StringCapName(i))
+		}
+	}
+	for _, i := range []int{CursorAddress, SetAForeground, KeyMouse} {
+		if IsObsolete(CapFamilyString, i) {
+			t.Errorf("string cap %d (%s) should not be obsolete", i, StringCapName(i))
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	ti, err := Load("vt100")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s, exp := string(ti.Reset()), "\x1b<\x1b>\x1b[?3;4;5l\x1b[?7;8h\x1b[r"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+}
+
+func TestLoadForFile(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "tty")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer f.Close()
+	if _, err := LoadForFile(f); err != ErrNotTerminal {
+		t.Errorf("expected %v, got: %v", ErrNotTerminal, err)
+	}
+}
+
+func TestStatusLine(t *testing.T) {
+	ti := &Terminfo{
+		Bools: map[int]bool{HasStatusLine: true},
+		Strings: map[int][]byte{
+			ToStatusLine:   []byte("\x1b]0;"),
+			FromStatusLine: []byte("\x07"),
+		},
+	}
+	buf, err := ti.StatusLine("title")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s, exp := string(buf), "\x1b]0;title\x07"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+	vt100, err := Load("vt100")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err := vt100.StatusLine("title"); err != ErrNoStatusLine {
+		t.Errorf("expected %v, got: %v", ErrNoStatusLine, err)
+	}
+}
+
+func TestRegisterExtCap(t *testing.T) {
+	i := RegisterExtCap(CapFamilyString, "XTestCap")
+	if i < 0 {
+		t.Fatalf("expected valid index, got: %d", i)
+	}
+	if j := RegisterExtCap(CapFamilyString, "XTestCap"); j != i {
+		t.Errorf("expected index %d, got: %d", i, j)
+	}
+	if j := ExtCapIndex(CapFamilyString, "XTestCap"); j != i {
+		t.Errorf("expected index %d, got: %d", i, j)
+	}
+	if j := ExtCapIndex(CapFamilyBool, "XTestCap"); j != -1 {
+		t.Errorf("expected -1, got: %d", j)
+	}
+	if j := RegisterExtCap(CapFamily(42), "XTestCap"); j != -1 {
+		t.Errorf("expected -1, got: %d", j)
+	}
+}
+
+func TestMemDB(t *testing.T) {
+	ti := &Terminfo{Names: []string{"test"}}
+	db := NewMemDB(map[string]*Terminfo{"test": ti})
+	z, err := db.Load("test")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if z != ti {
+		t.Errorf("expected loaded terminfo to be the same")
+	}
+	if _, err := db.Load("missing"); err != ErrFileNotFound {
+		t.Errorf("expected %v, got: %v", ErrFileNotFound, err)
+	}
+	if _, err := db.Load(""); err != ErrEmptyTermName {
+		t.Errorf("expected %v, got: %v", ErrEmptyTermName, err)
+	}
+	if _, err := SystemDB.Load("vt100"); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+}
+
+func TestLoadConcurrent(t *testing.T) {
+	const term = "vt100"
+	// clear cache
+	termCache.Lock()
+	for n, ti := range termCache.db {
+		if ti.File != "" && filepath.Base(ti.File) == term {
+			delete(termCache.db, n)
+		}
+	}
+	termCache.Unlock()
+	var wg sync.WaitGroup
+	tis := make([]*Terminfo, 16)
+	for i := range tis {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ti, err := Load(term)
+			if err != nil {
+				t.Errorf("expected no error, got: %v", err)
+			}
+			tis[i] = ti
+		}(i)
+	}
+	wg.Wait()
+	for i, ti := range tis {
+		if ti != tis[0] {
+			t.Errorf("load %d should return the same terminfo", i)
+		}
+	}
+}
+
+func TestExtBool(t *testing.T) {
+	ti := &Terminfo{
+		ExtBools:     map[int]bool{0: true, 1: true},
+		ExtBoolNames: map[int][]byte{0: []byte("AX"), 1: []byte("Sxl")},
+	}
+	if v, ok := ti.ExtBool("AX"); !v || !ok {
+		t.Errorf("expected AX to be present and true")
+	}
+	if _, ok := ti.ExtBool("XT"); ok {
+		t.Errorf("expected XT to not be present")
+	}
+	if !ti.Sixel() {
+		t.Errorf("expected sixel support")
+	}
+}
+
+func TestEscapeCap(t *testing.T) {
+	if _, err := exec.LookPath("tic"); err != nil {
+		t.Skip("tic not installed")
+	}
+	var all []byte
+	for i := 1; i < 256; i++ {
+		all = append(all, byte(i))
+	}
+	values := []string{
+		"\x1b[H\x1b[2J",
+		"\x1b[%i%p1%d;%p2%dH",
+		"\x07",
+		"\r\n\x1b[A",
+		"a,b:c!d^e",
+		"\x80",
+		"\x1b[H\x1b[2J$<50>",
+		"a\\b",
+		"\\E",
+		"\\072",
+		"%\\",
+		"%,",
+		"%^%p1",
+		"\\",
+		"1\r",
+		string(all),
+	}
+	ti := &Terminfo{
+		Names:          []string{"escape-cap-test"},
+		ExtStrings:     make(map[int][]byte),
+		ExtStringNames: make(map[int][]byte),
+	}
+	for i, v := range values {
+		ti.ExtStrings[i], ti.ExtStringNames[i] = []byte(v), []byte(fmt.Sprintf("Xe%d", i))
+	}
+	// compile the source with tic, and decode the compiled entry
+	dir := t.TempDir()
+	src := filepath.Join(dir, "escape-cap-test.src")
+	buf := new(bytes.Buffer)
+	writeSource(buf, ti, ti.sourceCaps())
+	if err := os.WriteFile(src, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if out, err := exec.Command("tic", "-x", "-o", dir, src).CombinedOutput(); err != nil {
+		t.Fatalf("expected no error, got: %v: %s", err, out)
+	}
+	z, err := Open(dir, "escape-cap-test")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	caps := z.ExtStringCaps()
+	for i, v := range values {
+		if s := string(caps[fmt.Sprintf("Xe%d", i)]); s != v {
+			t.Errorf("test %d escaped as %q expected %q, got: %q", i, EscapeCap([]byte(v)), v, s)
+		}
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	ti, err := Load("xterm-256color")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	m := ti.Flatten()
+	if v, ok := m["max_colors"]; !ok || v.Family != CapFamilyNum || v.Num != 256 {
+		t.Errorf("expected max_colors to be 256, got: %+v", v)
+	}
+	if v, ok := m["auto_right_margin"]; !ok || v.Family != CapFamilyBool || !v.Bool {
+		t.Errorf("expected auto_right_margin to be true, got: %+v", v)
+	}
+	if v, ok := m["cursor_address"]; !ok || v.Family != CapFamilyString || string(v.String) != "\x1b[%i%p1%d;%p2%dH" {
+		t.Errorf("expected cursor_address to be defined, got: %+v", v)
+	}
+	for k, v := range ti.ExtStringCaps() {
+		if z, ok := m[k]; !ok || !z.Extended || string(z.String) != string(v) {
+			t.Errorf("expected extended string cap %s to be defined, got: %+v", k, z)
+		}
+	}
+	// false and nil extended caps are not present
+	ti = &Terminfo{
+		ExtBools:       map[int]bool{0: false, 1: true},
+		ExtBoolNames:   map[int][]byte{0: []byte("XF"), 1: []byte("XT")},
+		ExtStrings:     map[int][]byte{0: nil},
+		ExtStringNames: map[int][]byte{0: []byte("Xs")},
+	}
+	if m, exp := ti.Flatten(), map[string]Value{"XT": {Family: CapFamilyBool, Extended: true, Bool: true}}; !reflect.DeepEqual(m, exp) {
+		t.Errorf("expected %+v, got: %+v", exp, m)
+	}
+}
+
+func TestEntries(t *testing.T) {
+	ti, err := Load("xterm-256color")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	entries := ti.Entries()
+	if len(entries) != len(ti.Flatten()) {
+		t.Fatalf("expected %d entries, got: %d", len(ti.Flatten()), len(entries))
+	}
+	if e := entries[0]; e.Name != "auto_right_margin" || e.Short != "am" || e.Value != true || e.Family != CapFamilyBool || e.Extended {
+		t.Errorf("expected auto_right_margin to be first, got: %+v", e)
+	}
+	for i := 1; i < len(entries); i++ {
+		a, b := entries[i-1], entries[i]
+		if a.Extended && !b.Extended || a.Extended == b.Extended && a.Family > b.Family {
+			t.Errorf("entry %s (%d) is out of order with %s", b.Name, i, a.Name)
+		}
+	}
+	var found bool
+	for _, e := range entries {
+		if e.Name == "max_colors" {
+			found = e.Short == "colors" && e.Value == 256
+		}
+	}
+	if !found {
+		t.Errorf("expected max_colors to be 256")
+	}
+	// false extended bools are not present
+	ti = &Terminfo{
+		ExtBools:     map[int]bool{0: false, 1: true},
+		ExtBoolNames: map[int][]byte{0: []byte("XF"), 1: []byte("XT")},
+	}
+	if entries, exp := ti.Entries(), []CapEntry{{"XT", "XT", true, CapFamilyBool, true}}; !reflect.DeepEqual(entries, exp) {
+		t.Errorf("expected %+v, got: %+v", exp, entries)
+	}
+}
+
+func TestPrintf(t *testing.T) {
+	tests := []struct {
+		z      string
+		params []interface{}
+		exp    string
+	}{
+		{"%p1%d", []interface{}{42}, "42"},
+		{"%p1%x", []interface{}{255}, "ff"},
+		{"%p1%X", []interface{}{255}, "FF"},
+		{"%p1%o", []interface{}{8}, "10"},
+		{"%p1%02d", []interface{}{5}, "05"},
+		{"%p1%#x", []interface{}{255}, "0xff"},
+		{"%p1%:-3dx", []interface{}{7}, "7  x"},
+		{"%p1%:d", []interface{}{5}, "5"},
+		{"\x1b[%p1%:dG", []interface{}{5}, "\x1b[5G"},
+		{"%p1%:x", []interface{}{255}, "ff"},
+		{"%r%p1%d;%p2%d", []interface{}{1, 2}, "2;1"},
+		{"%%", nil, "%"},
+		{
+			"\x1b]4;%p1%d;rgb:%p2%{255}%*%{1000}%/%2.2X/%p3%{255}%*%{1000}%/%2.2X/%p4%{255}%*%{1000}%/%2.2X\x1b\\",
+			[]interface{}{1, 1000, 0, 500},
+			"\x1b]4;1;rgb:FF/00/7F\x1b\\",
+		},
+		{"\x1b[%i%p1%d;%p2%dH", []interface{}{5, 10}, "\x1b[6;11H"},
+		// chars and ints are interchangeable
+		{"%p1%' '%+%c", []interface{}{1}, "!"},
+		{"%p1%c", []interface{}{65}, "A"},
+		{"%'A'%d", nil, "65"},
+		{"%p1%{48}%+%c", []interface{}{byte(7)}, "7"},
+		{"%?%p1%t1%e0%;", []interface{}{2}, "1"},
+		{"%?%p1%t1%e0%;", []interface{}{0}, "0"},
+		{"%p1%{1}%=%{1}%+%d", []interface{}{1}, "2"},
+		{"%?%p1%{8}%<%t3%p1%d%e9%p1%{8}%-%d%;", []interface{}{9}, "91"},
+		// dynamic and static variables
+		{"%p1%Pa%p2%Pb%gb%d;%ga%d", []interface{}{1, 2}, "2;1"},
+		{"%p1%PZ%gZ%gZ%+%d", []interface{}{21}, "42"},
+		{"%ga%d%gq%d", nil, "00"},
+		// overflow and division by zero
+		{"%p1%{1}%+%d", []interface{}{math.MaxInt}, strconv.Itoa(math.MinInt)},
+		{"%p1%p2%/%d", []interface{}{math.MinInt, -1}, strconv.Itoa(math.MinInt)},
+		{"%p1%{0}%/%d", []interface{}{5}, "0"},
+		{"%p1%{0}%m%d", []interface{}{5}, "0"},
+	}
+	for i, test := range tests {
+		if s := Printf([]byte(test.z), test.params...); s != test.exp {
+			t.Errorf("test %d expected %q, got: %q", i, test.exp, s)
+		}
+		instrs, err := compile([]byte(test.z))
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if s := (CompiledCap{instrs: instrs}).Exec(test.params...); s != test.exp {
+			t.Errorf("test %d expected compiled %q, got: %q", i, test.exp, s)
+		}
+	}
+}
+
+func TestPreferLocal(t *testing.T) {
+	defer SetPreferLocal(true)
+	for _, test := range []struct {
+		preferLocal bool
+		exp         []string
+	}{
+		{true, []string{"/etc/terminfo", "/lib/terminfo", "/usr/share/terminfo"}},
+		{false, []string{"/lib/terminfo", "/usr/share/terminfo", "/etc/terminfo"}},
+	} {
+		SetPreferLocal(test.preferLocal)
+		d, err := dirs()
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if len(d) < 3 || !reflect.DeepEqual(d[len(d)-3:], test.exp) {
+			t.Errorf("preferLocal %t expected dirs to end with %v, got: %v", test.preferLocal, test.exp, d)
+		}
+	}
+	// changing the order clears the cache
+	if _, err := Load("vt100"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	SetPreferLocal(false)
+	termCache.RLock()
+	_, ok := termCache.db["vt100"]
+	termCache.RUnlock()
+	if !ok {
+		t.Errorf("expected vt100 to still be cached")
+	}
+	SetPreferLocal(true)
+	termCache.RLock()
+	_, ok = termCache.db["vt100"]
+	termCache.RUnlock()
+	if ok {
+		t.Errorf("expected vt100 to no longer be cached")
+	}
+}
+
+func TestExtendedKeys(t *testing.T) {
+	ti, err := Load("xterm-256color")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	keys := ti.ExtendedKeys()
+	if s, exp := string(keys["kUP3"]), "\x1b[1;3A"; s != exp {
+		t.Errorf("expected kUP3 to be %q, got: %q", exp, s)
+	}
+	for name := range keys {
+		if !strings.HasPrefix(name, "k") {
+			t.Errorf("expected %s to not be a key", name)
+		}
+	}
+}
+
+func TestValidateAll(t *testing.T) {
+	for ts := range terms(t) {
+		term := ts
+		t.Run(term, func(t *testing.T) {
+			ti, err := Load(term)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			for _, err := range ti.ValidateAll() {
+				t.Errorf("expected no error, got: %v", err)
+			}
+		})
+	}
+	ti := &Terminfo{
+		Strings: map[int][]byte{
+			CursorAddress:  []byte("\x1b[%i%p1%d;%p2%dH"),
+			SetAForeground: []byte("\x1b[%?%p1%{8}%<%t3%p1%dm"),
+			SetABackground: []byte("\x1b[4%p1%"),
+		},
+	}
+	errs := ti.ValidateAll()
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got: %v", errs)
+	}
+	for i, exp := range []string{"set_a_foreground", "set_a_background"} {
+		if err, ok := errs[i].(*ParamError); !ok || err.Cap != exp {
+			t.Errorf("expected error %d to be for %s, got: %v", i, exp, errs[i])
+		}
+	}
+}
+
+func TestDecodeTrailingBytes(t *testing.T) {
+	for _, test := range []struct {
+		term     string
+		extended bool
+	}{
+		{"vt100", false},
+		{"vt220", false},
+		{"dumb", false},
+		{"xterm-256color", true},
+	} {
+		ti, err := Load(test.term)
+		if err != nil {
+			t.Fatalf("term %s expected no error, got: %v", test.term, err)
+		}
+		buf, err := os.ReadFile(ti.File)
+		if err != nil {
+			t.Fatalf("term %s expected no error, got: %v", test.term, err)
+		}
+		for _, b := range []byte{0, 0x01, 0x41, 0xff} {
+			for _, n := range []int{1, 2, 3, 9, 16, 64} {
+				z, err := Decode(append(buf[:len(buf):len(buf)], bytes.Repeat([]byte{b}, n)...))
+				// without an extended section, 10 or more bytes are read as an
+				// extended header, that is only valid when all zeros
+				if !test.extended && n >= 10 && b != 0 {
+					if err == nil {
+						t.Errorf("term %s with %d trailing %#x bytes expected error", test.term, n, b)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("term %s with %d trailing %#x bytes expected no error, got: %v", test.term, n, b, err)
+				}
+				if z.Unaligned {
+					t.Errorf("term %s with %d trailing %#x bytes expected unaligned to be false", test.term, n, b)
+				}
+				if !reflect.DeepEqual(z.Strings, ti.Strings) || !reflect.DeepEqual(z.ExtStringCaps(), ti.ExtStringCaps()) {
+					t.Errorf("term %s with %d trailing %#x bytes should decode the same caps", test.term, n, b)
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	ti, err := Load("xterm-256color")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	buf, err := os.ReadFile(ti.File)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(ti.ExtStringNames) == 0 {
+		t.Fatalf("expected xterm-256color to have extended caps")
+	}
+	for _, n := range []int{1, 2, 20, 100} {
+		if _, err := Decode(buf[:len(buf)-n]); err != ErrUnexpectedFileEnd {
+			t.Errorf("truncated by %d expected %v, got: %v", n, ErrUnexpectedFileEnd, err)
+		}
+	}
+}
+
+func TestSaveRestoreCursor(t *testing.T) {
+	ti, err := Load("vt100")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s, exp := string(ti.SaveCursor()), "\x1b7"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+	if s, exp := string(ti.RestoreCursor()), "\x1b8"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+}
+
+func TestDiffSource(t *testing.T) {
+	a := &Terminfo{
+		Names:    []string{"base"},
+		LongName: "base terminal",
+		Bools:    map[int]bool{AutoRightMargin: true, XonXoff: true},
+		Nums:     map[int]int{Columns: 80, Lines: 24},
+		Strings: map[int][]byte{
+			Bell:          []byte("\x07"),
+			CursorAddress: []byte("\x1b[%i%p1%d;%p2%dH"),
+		},
+	}
+	b := &Terminfo{
+		Names:    []string{"variant", "var"},
+		LongName: "variant terminal",
+		Bools:    map[int]bool{AutoRightMargin: true, BackColorErase: true},
+		Nums:     map[int]int{Columns: 132, Lines: 24},
+		Strings: map[int][]byte{
+			CursorAddress: []byte("\x1b[%i%p1%d;%p2%dH"),
+			ClearScreen:   []byte("\x1b[H\x1b[2J$<50>"),
+		},
+		ExtBools:     map[int]bool{0: true},
+		ExtBoolNames: map[int][]byte{0: []byte("XT")},
+	}
+	buf := new(strings.Builder)
+	if err := DiffSource(a, b, buf); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	exp := "variant|var|variant terminal,\n" +
+		"\tXT,\n" +
+		"\tbce,\n" +
+		"\txon@,\n" +
+		"\tcols#132,\n" +
+		"\tbel@,\n" +
+		"\tclear=\\E[H\\E[2J$<50>,\n" +
+		"\tuse=base,\n"
+	if s := buf.String(); s != exp {
+		t.Errorf("expected:\n%s\ngot:\n%s", exp, s)
+	}
+}
+
+func TestInvalidParam(t *testing.T) {
+	for _, z := range []string{"\x1b[%p0%dm", "\x1b[%pA%dm", "\x1b[%p"} {
+		_, err := analyzeParams([]byte(z))
+		if err == nil {
+			t.Fatalf("%q expected error", z)
+		}
+		if s := err.(*ParamError).Seq; !strings.HasPrefix(s, "%p") {
+			t.Errorf("%q expected error to name the %%p sequence, got: %q", z, s)
+		}
+		// evaluating should not panic
+		_ = Printf([]byte(z), 1)
+	}
+}
+
+func TestInvalidFormat(t *testing.T) {
+	tests := []struct {
+		z   string
+		exp string
+	}{
+		{"%p1%:%d", "%:1"},
+		{"%p1%.%d", "%.1"},
+		{"%p1%p2%:x%{3}%*%d", "23"},
+		{"%p1%p2%:-*%d", "%:-*2"},
+		{"%p1%:5:d", "%:5:d"},
+	}
+	for i, test := range tests {
+		if s := Printf([]byte(test.z), 1, 2); s != test.exp {
+			t.Errorf("test %d expected %q, got: %q", i, test.exp, s)
+		}
+	}
+	for i, z := range []string{"%p1%:%d", "%p1%.%d", "%p1%p2%:-*%d", "%p1%:5:d"} {
+		if _, err := analyzeParams([]byte(z)); err == nil {
+			t.Errorf("test %d %q expected error", i, z)
+		}
+	}
+}
+
+func TestDecodeUnaligned(t *testing.T) {
+	ti, err := Load("xterm")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	buf, err := os.ReadFile(ti.File)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	// xterm has an odd length names and bools section, so strip the alignment
+	// byte following the bools and drop the extended section
+	h := make([]int, 6)
+	for i := range h {
+		h[i] = int(int16(buf[2*i+1])<<8 | int16(buf[2*i]))
+	}
+	pos := 12 + h[fieldNameSize] + h[fieldBoolCount]
+	if pos%2 != 1 {
+		t.Fatalf("expected xterm to have an odd length names and bools section")
+	}
+	end := pos + 1 + h[fieldNumCount]*2 + h[fieldStringCount]*2 + h[fieldTableSize]
+	z, err := Decode(append(append([]byte{}, buf[:pos]...), buf[pos+1:end]...))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !z.Unaligned {
+		t.Errorf("expected unaligned to be true")
+	}
+	if !reflect.DeepEqual(z.Strings, ti.Strings) || !reflect.DeepEqual(z.Nums, ti.Nums) {
+		t.Errorf("expected unaligned file to decode the same caps")
+	}
+	if ti.Unaligned {
+		t.Errorf("expected unaligned to be false")
+	}
+}
+
+func TestDecodeUnalignedRetry(t *testing.T) {
+	// corrupting a file must not cause it to be decoded without the
+	// alignment bytes, as that only happens for errors in the num and
+	// string sections of files with an odd length names and bools section
+	for _, term := range []string{"ansi", "xterm-256color"} {
+		ti, err := Load(term)
+		if err != nil {
+			t.Fatalf("term %s expected no error, got: %v", term, err)
+		}
+		buf, err := os.ReadFile(ti.File)
+		if err != nil {
+			t.Fatalf("term %s expected no error, got: %v", term, err)
+		}
+		for i := range buf {
+			for _, b := range []byte{0x01, 0x80, 0xff} {
+				z := append([]byte{}, buf...)
+				z[i] = b
+				if ti, err := Decode(z); err == nil && ti.Unaligned {
+					t.Errorf("term %s byte %d set to %#x expected to not be decoded as unaligned", term, i, b)
+				}
+			}
+		}
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	ti, err := Load("vt100")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	buf := new(bytes.Buffer)
+	term := NewTerminal(ti, buf)
+	if err := term.Clear(); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := term.MoveTo(10, 5); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := term.MoveTo(-1, 5); err != ErrNegativeCoordinate {
+		t.Errorf("expected %v, got: %v", ErrNegativeCoordinate, err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected output to be buffered")
+	}
+	if err := term.Flush(); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	// vt100 uses xon/xoff, so padding is not applied
+	if s, exp := buf.String(), "\x1b[H\x1b[J\x1b[6;11H"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+}
+
+func TestGotoChecked(t *testing.T) {
+	ti, err := Load("xterm-256color")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s, err := ti.GotoChecked(5, 10); err != nil || s != "\x1b[6;11H" {
+		t.Errorf("expected %q, got: %q, %v", "\x1b[6;11H", s, err)
+	}
+	for _, c := range [][2]int{{-1, 0}, {0, -1}, {-1, -1}} {
+		if s, err := ti.GotoChecked(c[0], c[1]); err != ErrNegativeCoordinate || s != "" {
+			t.Errorf("%v: expected %v, got: %q, %v", c, ErrNegativeCoordinate, s, err)
+		}
+	}
+}
+
+func TestParseDelay(t *testing.T) {
+	tests := []struct {
+		spec      string
+		delay     time.Duration
+		mandatory bool
+	}{
+		{"5", 5 * time.Millisecond, false},
+		{"1.5*", 1500 * time.Microsecond, false},
+		{"10/", 10 * time.Millisecond, true},
+		{"10*/", 10 * time.Millisecond, true},
+		{"x", 0, false},
+	}
+	for i, test := range tests {
+		delay, mandatory := parseDelay([]byte(test.spec))
+		if delay != test.delay || mandatory != test.mandatory {
+			t.Errorf("test %d expected %v %t, got: %v %t", i, test.delay, test.mandatory, delay, mandatory)
+		}
+	}
+}
+
+func TestUnknownCaps(t *testing.T) {
+	for ts := range terms(t) {
+		term := ts
+		t.Run(term, func(t *testing.T) {
+			ti, err := Load(term)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if names := ti.UnknownCaps(); len(names) != 0 {
+				t.Errorf("expected no unknown caps, got: %v", names)
+			}
+		})
+	}
+	ti := &Terminfo{
+		ExtNums:        map[int]int{0: 256},
+		ExtNumNames:    map[int][]byte{0: []byte("colro")},
+		ExtStrings:     map[int][]byte{0: []byte("\x1b[1;3A")},
+		ExtStringNames: map[int][]byte{0: []byte("kUP3")},
+	}
+	if names := ti.UnknownCaps(); !reflect.DeepEqual(names, []string{"colro"}) {
+		t.Errorf("expected [colro], got: %v", names)
+	}
+}
+
+// buildTerminfo builds a terminfo file with the extended number format, with
+// the num caps nums and the string caps strs.
+func buildTerminfo(names string, nums map[int]int, strs map[int][]byte) []byte {
+	return buildTerminfoOrder(binary.LittleEndian, names, nums, strs)
+}
+
+// buildTerminfoOrder builds a terminfo file as buildTerminfo, using the byte
+// order.
+func buildTerminfoOrder(order binary.ByteOrder, names string, nums map[int]int, strs map[int][]byte) []byte {
+	numCount, n := 0, 0
+	for i := range nums {
+		if i+1 > numCount {
+			numCount = i + 1
+		}
+	}
+	for i := range strs {
+		if i+1 > n {
+			n = i + 1
+		}
+	}
+	var table []byte
+	offsets := make([]int, n)
+	for i := 0; i < n; i++ {
+		v, ok := strs[i]
+		if !ok {
+			offsets[i] = -1
+			continue
+		}
+		offsets[i] = len(table)
+		table = append(append(table, v...), 0)
+	}
+	buf := new(bytes.Buffer)
+	put := func(v int) {
+		var b [2]byte
+		order.PutUint16(b[:], uint16(v))
+		buf.Write(b[:])
+	}
+	nameSize := len(names) + 1
+	for _, v := range []int{magicExtended, nameSize, 0, numCount, n, len(table)} {
+		put(v)
+	}
+	buf.WriteString(names)
+	buf.WriteByte(0)
+	if nameSize%2 != 0 {
+		buf.WriteByte(0)
+	}
+	for i := 0; i < numCount; i++ {
+		v, ok := nums[i]
+		if !ok {
+			v = -1
+		}
+		var b [4]byte
+		order.PutUint32(b[:], uint32(v))
+		buf.Write(b[:])
+	}
+	for _, v := range offsets {
+		put(v)
+	}
+	buf.Write(table)
+	return buf.Bytes()
+}
+
+func TestDecodeLarge(t *testing.T) {
+	big := bytes.Repeat([]byte("\x1b[0m"), 2500)
+	buf := buildTerminfo("big|big test", nil, map[int][]byte{
+		ClearScreen:   big,
+		CursorAddress: []byte("\x1b[%i%p1%d;%p2%dH"),
+		KeyMouse:      []byte("\x1b[M"),
+	})
+	if len(buf) <= 8192 {
+		t.Fatalf("expected a large entry, got: %d bytes", len(buf))
+	}
+	ti, err := Decode(buf)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !bytes.Equal(ti.Strings[ClearScreen], big) {
+		t.Errorf("expected clear_screen to be decoded")
+	}
+	if s, exp := string(ti.Strings[KeyMouse]), "\x1b[M"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+	if _, err := Decode(make([]byte, 32769)); err != ErrInvalidFileSize {
+		t.Errorf("expected %v, got: %v", ErrInvalidFileSize, err)
+	}
+}
+
+func TestColumnsLines(t *testing.T) {
+	ti, err := Load("vt100")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if n, ok := ti.Columns(); !ok || n != 80 {
+		t.Errorf("expected 80, true, got: %d, %t", n, ok)
+	}
+	if n, ok := ti.Lines(); !ok || n != 24 {
+		t.Errorf("expected 24, true, got: %d, %t", n, ok)
+	}
+	ti = &Terminfo{Nums: map[int]int{Columns: -1}}
+	if n, ok := ti.Columns(); ok || n != 0 {
+		t.Errorf("expected 0, false, got: %d, %t", n, ok)
+	}
+	if n, ok := ti.Lines(); ok || n != 0 {
+		t.Errorf("expected 0, false, got: %d, %t", n, ok)
+	}
+}
+
+func TestOrigPair(t *testing.T) {
+	ti, err := Load("xterm-256color")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s, exp := string(ti.OrigPair()), "\x1b[39;49m"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+	if s, exp := string(ti.OrigColors()), "\x1b]104\a"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+	if s := (&Terminfo{}).OrigPair(); s != nil {
+		t.Errorf("expected nil, got: %q", s)
+	}
+}
+
+func TestParamContext(t *testing.T) {
+	// static variables persist within a context
+	var ctx ParamContext
+	ctx.Printf([]byte("%p1%PA"), 5)
+	if s, exp := ctx.Printf([]byte("%gA%d")), "5"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+	// and are not shared with other contexts
+	other := new(ParamContext)
+	if s, exp := other.Printf([]byte("%?%gA%t1%e0%;")), "0"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+	// dynamic variables do not persist between evaluations
+	if s, exp := ctx.Printf([]byte("%p1%Pa%ga%d"), 7), "7"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+	if s, exp := ctx.Printf([]byte("%?%ga%t1%e0%;")), "0"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+	// compiled caps use the context's static variables
+	instrs, err := compile([]byte("%gA%p1%+%d"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	c := CompiledCap{instrs: instrs}
+	if s, exp := ctx.Exec(c, 2), "7"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+	if s, exp := other.Exec(c, 2), "2"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+}
+
+func TestNeedsParams(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp bool
+	}{
+		{"", false},
+		{"\x1b[H\x1b[2J", false},
+		{"100%%", false},
+		{"\x1b[%i%p1%d;%p2%dH", true},
+		{"\x1b[%?%p9%t1%;m", true},
+		{"\x1b[%p", false},
+	}
+	for i, test := range tests {
+		if b := NeedsParams([]byte(test.s)); b != test.exp {
+			t.Errorf("test %d %q expected %t, got: %t", i, test.s, test.exp, b)
+		}
+	}
+}
+
+func TestDecodeCancelledNum(t *testing.T) {
+	buf := buildTerminfo("cancel|cancelled test", map[int]int{
+		Columns:   100000,
+		MaxColors: 0x1000000,
+		Lines:     -2,
+	}, nil)
+	ti, err := Decode(buf)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if n := ti.Num(Columns); n != 100000 {
+		t.Errorf("expected 100000, got: %d", n)
+	}
+	if n := ti.Num(MaxColors); n != 0x1000000 {
+		t.Errorf("expected %d, got: %d", 0x1000000, n)
+	}
+	if !ti.NumsM[Lines] {
+		t.Errorf("expected lines to be cancelled")
+	}
+	if n := ti.Num(Lines); n != -1 {
+		t.Errorf("expected -1, got: %d", n)
+	}
+	if n, ok := ti.Lines(); ok {
+		t.Errorf("expected lines to not be present, got: %d", n)
+	}
+}
+
+func TestColorCache(t *testing.T) {
+	ti, err := Load("xterm-256color")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	c := ti.ColorCache()
+	for _, i := range []int{0, 5, 9, 255} {
+		if s, exp := string(c.Fg(i)), ti.Printf(SetAForeground, i); s != exp {
+			t.Errorf("color %d expected %q, got: %q", i, exp, s)
+		}
+		if s, exp := string(c.Bg(i)), ti.Printf(SetABackground, i); s != exp {
+			t.Errorf("color %d expected %q, got: %q", i, exp, s)
+		}
+	}
+	for _, i := range []int{-1, 256} {
+		if s := c.Fg(i); s != nil {
+			t.Errorf("color %d expected nil, got: %q", i, s)
+		}
+	}
+	ti, err = Load("vt100")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s := ti.ColorCache().Fg(1); s != nil {
+		t.Errorf("expected nil, got: %q", s)
+	}
+}
+
+func TestNoColorVideo(t *testing.T) {
+	// linux console style ncv#18, underline and dim
+	ti := &Terminfo{Nums: map[int]int{NoColorVideo: 18}}
+	v := ti.NoColorVideo()
+	if !v.Has(VideoUnderline) || !v.Has(VideoDim) || !v.Has(VideoUnderline|VideoDim) {
+		t.Errorf("expected underline and dim, got: %b", v)
+	}
+	if v.Has(VideoBold) || v.Has(VideoReverse) {
+		t.Errorf("expected no bold or reverse, got: %b", v)
+	}
+	if v := (&Terminfo{Nums: map[int]int{NoColorVideo: -1}}).NoColorVideo(); v != 0 {
+		t.Errorf("expected no attributes, got: %b", v)
+	}
+	if VideoAltCharset != 256 || VideoItalic != 32768 {
+		t.Errorf("unexpected attribute bit positions")
+	}
+}
+
+func TestCompile(t *testing.T) {
+	for _, term := range []string{"xterm-256color", "vt100", "vt220", "ansi", "screen.xterm-256color"} {
+		t.Run(term, func(t *testing.T) {
+			ti, err := Load(term)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			for i := 0; i < CapCountString; i++ {
+				if ti.Strings[i] == nil || i == AcsChars || i == User6 || i == User8 {
+					continue
+				}
+				c, err := ti.Compile(i)
+				if err != nil {
+					t.Errorf("%s expected no error, got: %v", StringCapName(i), err)
+					continue
+				}
+				for _, params := range [][]interface{}{nil, {0, 0}, {5, 10}, {1, 2, 3, 4, 5, 6, 7, 8, 9}} {
+					if s, exp := c.Exec(params...), ti.Printf(i, params...); s != exp {
+						t.Errorf("%s %v expected %q, got: %q", StringCapName(i), params, exp, s)
+					}
+				}
+			}
+		})
+	}
+	ti := &Terminfo{Strings: map[int][]byte{
+		CursorAddress:  []byte("\x1b[%i%p1%d;%p2%dH"),
+		SetAForeground: []byte("\x1b[%?%p1%{8}%<%t3%p1%d%e%p1%{16}%<%t9%p1%{8}%-%d%e38;5;%p1%d%;m"),
+		ClearScreen:    []byte("%p1%"),
+	}}
+	c, err := ti.Compile(CursorAddress)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s, exp := c.Exec(5, 10), "\x1b[6;11H"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+	if c, err = ti.Compile(SetAForeground); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	for color, exp := range map[int]string{1: "\x1b[31m", 9: "\x1b[91m", 100: "\x1b[38;5;100m"} {
+		if s := c.Exec(color); s != exp {
+			t.Errorf("color %d expected %q, got: %q", color, exp, s)
+		}
+	}
+	if _, err := ti.Compile(ClearScreen); err == nil {
+		t.Errorf("expected error")
+	}
+	// out of range indexes with malformed values
+	ti.Strings[-1], ti.Strings[CapCountString] = []byte("%p1%"), []byte("%p1%")
+	for _, i := range []int{-1, CapCountString} {
+		if _, err := ti.Compile(i); err != ErrInvalidCapIndex {
+			t.Errorf("index %d expected %v, got: %v", i, ErrInvalidCapIndex, err)
+		}
+	}
+}
+
+func BenchmarkPrintf(b *testing.B) {
+	z := []byte("\x1b[%i%p1%d;%p2%dH")
+	for i := 0; i < b.N; i++ {
+		_ = Printf(z, 5, 10)
+	}
+}
+
+func BenchmarkCompiledCap(b *testing.B) {
+	ti := &Terminfo{Strings: map[int][]byte{CursorAddress: []byte("\x1b[%i%p1%d;%p2%dH")}}
+	c, err := ti.Compile(CursorAddress)
+	if err != nil {
+		b.Fatalf("expected no error, got: %v", err)
+	}
+	for i := 0; i < b.N; i++ {
+		_ = c.Exec(5, 10)
+	}
+}
+
+func TestCanonicalName(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "c"), 0o755); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	buf := buildTerminfo("canon-test|canonical name test", nil, map[int][]byte{ClearScreen: []byte("\x1b[H\x1b[2J")})
+	if err := os.WriteFile(filepath.Join(dir, "c", "canon-test"), buf, 0o644); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := os.Symlink("canon-test", filepath.Join(dir, "c", "canon-alias")); err != nil {
+		t.Skipf("unable to create symlink: %v", err)
+	}
+	for _, name := range []string{"canon-test", "canon-alias"} {
+		ti, err := Open(dir, name)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if exp := filepath.Join(dir, "c", name); ti.File != exp {
+			t.Errorf("expected %q, got: %q", exp, ti.File)
+		}
+		if ti.CanonicalName != "canon-test" {
+			t.Errorf("%s expected canon-test, got: %q", name, ti.CanonicalName)
+		}
+	}
+}
+
+func TestRawStringTable(t *testing.T) {
+	buf := buildTerminfo("raw-test|raw string table test", nil, map[int][]byte{
+		ClearScreen:   []byte("\x1b[H\x1b[2J"),
+		CursorAddress: []byte("\x1b[%i%p1%d;%p2%dH"),
+	})
+	ti, err := Decode(buf)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	// strings are stored in cap order
+	if s, exp := string(ti.RawStringTable()), "\x1b[H\x1b[2J\x00\x1b[%i%p1%d;%p2%dH\x00"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+	if !bytes.HasSuffix(buf, ti.RawStringTable()) {
+		t.Errorf("expected the raw string table to be the end of the file")
+	}
+	if s := (&Terminfo{}).RawStringTable(); s != nil {
+		t.Errorf("expected nil, got: %q", s)
+	}
+}
+
+func TestAttrs(t *testing.T) {
+	ti, err := Load("xterm-256color")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	tests := []struct {
+		f     func() ([]byte, []byte)
+		enter string
+		exit  string
+	}{
+		{ti.Bold, "\x1b[1m", "\x1b(B\x1b[m"},
+		{ti.Dim, "\x1b[2m", "\x1b(B\x1b[m"},
+		{ti.Blink, "\x1b[5m", "\x1b(B\x1b[m"},
+		{ti.Reverse, "\x1b[7m", "\x1b(B\x1b[m"},
+		{ti.Underline, "\x1b[4m", "\x1b[24m"},
+		{ti.Italic, "\x1b[3m", "\x1b[23m"},
+		{ti.Standout, "\x1b[7m", "\x1b[27m"},
+	}
+	for i, test := range tests {
+		enter, exit := test.f()
+		if string(enter) != test.enter || string(exit) != test.exit {
+			t.Errorf("test %d expected %q, %q, got: %q, %q", i, test.enter, test.exit, enter, exit)
+		}
+	}
+	// vt100 has no italics
+	if ti, err = Load("vt100"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if enter, exit := ti.Italic(); enter != nil || exit != nil {
+		t.Errorf("expected nil, got: %q, %q", enter, exit)
+	}
+}
+
+func TestTranslateNewlines(t *testing.T) {
+	tests := []struct {
+		ti  *Terminfo
+		p   string
+		exp string
+	}{
+		{&Terminfo{}, "", ""},
+		{&Terminfo{}, "a\nb\r\nc", "a\r\nb\r\nc"},
+		{&Terminfo{Strings: map[int][]byte{Newline: []byte("\x1bE")}}, "a\nb\r\n", "a\x1bEb\x1bE"},
+		{&Terminfo{Strings: map[int][]byte{CarriageReturn: []byte("\r"), ScrollForward: []byte("\x1bD")}}, "a\n\n", "a\r\x1bD\r\x1bD"},
+		{&Terminfo{}, "\r\r\n", "\r\r\n"},
+	}
+	for i, test := range tests {
+		if s := string(test.ti.TranslateNewlines([]byte(test.p))); s != test.exp {
+			t.Errorf("test %d expected %q, got: %q", i, test.exp, s)
+		}
+	}
+}
+
+func TestSoftLabels(t *testing.T) {
+	ti := &Terminfo{
+		Nums: map[int]int{NumLabels: 8, LabelHeight: 2, LabelWidth: 8},
+		Strings: map[int][]byte{
+			LabF2:    []byte("F2"),
+			LabF10:   []byte("F10"),
+			PlabNorm: []byte("\x1b&f%p1%dk%p2%l%dd0L%p2%s"),
+			LabelOn:  []byte("\x1b&jB"),
+			LabelOff: []byte("\x1b&j@"),
+		},
+	}
+	c := ti.SoftLabels()
+	if c.NumLabels != 8 || c.LabelHeight != 2 || c.LabelWidth != 8 {
+		t.Errorf("expected 8, 2, 8, got: %d, %d, %d", c.NumLabels, c.LabelHeight, c.LabelWidth)
+	}
+	if string(c.Labels[2]) != "F2" || string(c.Labels[10]) != "F10" || c.Labels[1] != nil {
+		t.Errorf("unexpected labels: %q", c.Labels)
+	}
+	if string(c.LabelOn) != "\x1b&jB" || string(c.LabelOff) != "\x1b&j@" || c.PlabNorm == nil {
+		t.Errorf("unexpected label caps: %+v", c)
+	}
+	if c := (&Terminfo{}).SoftLabels(); c.NumLabels != -1 {
+		t.Errorf("expected -1, got: %d", c.NumLabels)
+	}
+}
+
+func TestFlowControl(t *testing.T) {
+	ti := &Terminfo{
+		Bools:   map[int]bool{XonXoff: true},
+		Strings: map[int][]byte{EnterXonMode: []byte("\x1b[?8h"), ExitXonMode: []byte("\x1b[?8l")},
+	}
+	xon, smxon, rmxon := ti.FlowControl()
+	if !xon || string(smxon) != "\x1b[?8h" || string(rmxon) != "\x1b[?8l" {
+		t.Errorf("unexpected flow control caps: %t, %q, %q", xon, smxon, rmxon)
+	}
+	if xon, smxon, rmxon := (&Terminfo{}).FlowControl(); xon || smxon != nil || rmxon != nil {
+		t.Errorf("expected no flow control caps, got: %t, %q, %q", xon, smxon, rmxon)
+	}
+}
+
+func TestInsertDeleteMode(t *testing.T) {
+	ti, err := Load("xterm-256color")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if enter, exit := ti.InsertMode(); string(enter) != "\x1b[4h" || string(exit) != "\x1b[4l" {
+		t.Errorf("unexpected insert mode caps: %q, %q", enter, exit)
+	}
+	if enter, exit := ti.DeleteMode(); enter != nil || exit != nil {
+		t.Errorf("expected no delete mode caps, got: %q, %q", enter, exit)
+	}
+}
+
+func TestRequiredFor(t *testing.T) {
+	ti, err := Load("xterm-256color")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	for _, f := range []Feature{FeatureColor, FeatureAltScreen, FeatureMouse, FeatureCursorAddress, FeatureCursorVisibility, FeatureKeypad} {
+		if caps, ok := ti.RequiredFor(f); !ok || len(caps) == 0 {
+			t.Errorf("expected %s to be supported, got: %v, %t", f, caps, ok)
+		}
+	}
+	if ti, err = Load("vt100"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if caps, ok := ti.RequiredFor(FeatureColor); ok || len(caps) != 0 {
+		t.Errorf("expected color to not be supported, got: %v, %t", caps, ok)
+	}
+	if caps, ok := ti.RequiredFor(FeatureCursorAddress); !ok || !reflect.DeepEqual(caps, []int{CursorAddress}) {
+		t.Errorf("expected cursor address to be supported, got: %v, %t", caps, ok)
+	}
+	if caps, ok := ti.RequiredFor(Feature(100)); ok || caps != nil {
+		t.Errorf("expected unknown feature to not be supported, got: %v, %t", caps, ok)
+	}
+}
+
+// findNullLoop is the byte by byte implementation of findNull, used to
+// compare against in benchmarks.
+func findNullLoop(buf []byte, i int) int {
+	for ; i < len(buf); i++ {
+		if buf[i] == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestFindNull(t *testing.T) {
+	buf := []byte("\x1b[H\x00\x1b[2J\x00\x00abc")
+	for i := 0; i <= len(buf)+1; i++ {
+		if n, exp := findNull(buf, i), findNullLoop(buf, i); n != exp {
+			t.Errorf("position %d expected %d, got: %d", i, exp, n)
+		}
+	}
+}
+
+// benchStringTable returns the string table of xterm-256color.
+func benchStringTable(b *testing.B) []byte {
+	ti, err := Load("xterm-256color")
+	if err != nil {
+		b.Fatalf("expected no error, got: %v", err)
+	}
+	return ti.RawStringTable()
+}
+
+func BenchmarkFindNull(b *testing.B) {
+	buf := benchStringTable(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j != -1 && j < len(buf); j++ {
+			j = findNull(buf, j)
+		}
+	}
+}
+
+func BenchmarkFindNullLoop(b *testing.B) {
+	buf := benchStringTable(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j != -1 && j < len(buf); j++ {
+			j = findNullLoop(buf, j)
+		}
+	}
+}
+
+func BenchmarkFindNullLong(b *testing.B) {
+	buf := append(bytes.Repeat([]byte("\x1b[0m"), 2500), 0)
+	for i := 0; i < b.N; i++ {
+		_ = findNull(buf, 0)
+	}
+}
+
+func BenchmarkFindNullLoopLong(b *testing.B) {
+	buf := append(bytes.Repeat([]byte("\x1b[0m"), 2500), 0)
+	for i := 0; i < b.N; i++ {
+		_ = findNullLoop(buf, 0)
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	ti, err := Load("xterm-256color")
+	if err != nil {
+		b.Fatalf("expected no error, got: %v", err)
+	}
+	buf, err := os.ReadFile(ti.File)
+	if err != nil {
+		b.Fatalf("expected no error, got: %v", err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Decode(buf); err != nil {
+			b.Fatalf("expected no error, got: %v", err)
+		}
+	}
+}
+
+func TestDecodeBigEndian(t *testing.T) {
+	nums := map[int]int{Columns: 100000, Lines: -2}
+	strs := map[int][]byte{
+		ClearScreen:   []byte("\x1b[H\x1b[2J"),
+		CursorAddress: []byte("\x1b[%i%p1%d;%p2%dH"),
+	}
+	le, err := Decode(buildTerminfoOrder(binary.LittleEndian, "endian|endian test", nums, strs))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	be, err := Decode(buildTerminfoOrder(binary.BigEndian, "endian|endian test", nums, strs))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if le.BigEndian || !be.BigEndian {
+		t.Errorf("expected big endian to be detected, got: %t, %t", le.BigEndian, be.BigEndian)
+	}
+	if !reflect.DeepEqual(le.Nums, be.Nums) || !reflect.DeepEqual(le.NumsM, be.NumsM) {
+		t.Errorf("expected nums %v, got: %v", le.Nums, be.Nums)
+	}
+	if !reflect.DeepEqual(le.Strings, be.Strings) {
+		t.Errorf("expected strings %q, got: %q", le.Strings, be.Strings)
+	}
+	if n := be.Num(Columns); n != 100000 {
+		t.Errorf("expected 100000, got: %d", n)
+	}
+}
+
+func TestStringCapsContaining(t *testing.T) {
+	ti, err := Load("xterm-256color")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if z, exp := ti.StringCapsContaining([]byte("\x1b[?1049")), []int{EnterCaMode, ExitCaMode}; !reflect.DeepEqual(z, exp) {
+		t.Errorf("expected %v, got: %v", exp, z)
+	}
+	if z := ti.StringCapsContaining([]byte("not a sequence")); z != nil {
+		t.Errorf("expected nil, got: %v", z)
+	}
+}
+
+func TestEnableMouse(t *testing.T) {
+	ti, err := Load("xterm-256color")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	enable, disable, ok := ti.EnableMouse()
+	if !ok {
+		t.Fatalf("expected XM to be defined")
+	}
+	if s, exp := string(enable), "\x1b[?1006;1000h"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+	if s, exp := string(disable), "\x1b[?1006;1000l"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+	if ti, err = Load("vt100"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, _, ok := ti.EnableMouse(); ok {
+		t.Errorf("expected XM to not be defined")
+	}
+}
+
+func TestIntConstant(t *testing.T) {
+	tests := []struct {
+		z   string
+		exp string
+	}{
+		{"%{0}%d", "0"},
+		{"%{7}%d", "7"},
+		{"%{1000}%d", "1000"},
+		{"%p1%{1000}%*%{255}%/%d", "1000"},
+		{"%{65535}%{1}%+%d", "65536"},
+	}
+	for i, test := range tests {
+		if _, err := analyzeParams([]byte(test.z)); err != nil {
+			t.Errorf("test %d expected no error, got: %v", i, err)
+		}
+		if s := Printf([]byte(test.z), 255); s != test.exp {
+			t.Errorf("test %d expected %q, got: %q", i, test.exp, s)
+		}
+	}
+	for _, z := range []string{"%{}%d", "%{1a}%d", "%{-1}%d", "%{1 0}%d"} {
+		_, err := analyzeParams([]byte(z))
+		if err == nil {
+			t.Fatalf("%q expected error", z)
+		}
+		if s := err.(*ParamError).Seq; !strings.HasPrefix(s, "%{") || !strings.HasSuffix(s, "}") {
+			t.Errorf("%q expected error to name the %%{} sequence, got: %q", z, s)
+		}
+	}
+}
+
+func TestDecodeNoStrings(t *testing.T) {
+	ti, err := Load("dumb")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !ti.Bools[AutoRightMargin] || ti.Num(Columns) != 80 || string(ti.Strings[Bell]) != "\a" {
+		t.Errorf("unexpected dumb caps")
+	}
+	// an entry without any string caps, and an empty string table
+	ti, err = Decode(buildTerminfo("nostrings|no string caps", map[int]int{Columns: 80}, nil))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(ti.Strings) != 0 || len(ti.StringsM) != 0 || len(ti.RawStringTable()) != 0 {
+		t.Errorf("expected no strings, got: %q", ti.Strings)
+	}
+	if n := ti.Num(Columns); n != 80 {
+		t.Errorf("expected 80, got: %d", n)
+	}
+}
+
+func TestCanonicalizeAscChars(t *testing.T) {
+	tests := []struct {
+		z   string
+		exp string
+	}{
+		{"", ""},
+		{"qqxx", "qqxx"},
+		{"xxqq", "qqxx"},
+		// later pairs override earlier pairs
+		{"qaxxqq", "qqxx"},
+		// unpaired char is kept at the end
+		{"xxqqj", "qqxxj"},
+	}
+	for i, test := range tests {
+		if s := string(canonicalizeAscChars([]byte(test.z))); s != test.exp {
+			t.Errorf("test %d expected %q, got: %q", i, test.exp, s)
+		}
+	}
+	if z := canonicalizeAscChars(nil); z != nil {
+		t.Errorf("expected nil, got: %q", z)
+	}
+}
+
+func TestACSValid(t *testing.T) {
+	ti, err := Load("vt100")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !ti.ACSValid() {
+		t.Errorf("expected vt100 acsc to be valid")
+	}
+	ti = &Terminfo{Strings: map[int][]byte{AcsChars: canonicalizeAscChars([]byte("xxqqj"))}}
+	if ti.ACSValid() {
+		t.Errorf("expected odd length acsc to be invalid")
+	}
+	if errs := ti.ValidateAll(); len(errs) != 1 || errs[0] != ErrInvalidACSChars {
+		t.Errorf("expected %v, got: %v", ErrInvalidACSChars, errs)
+	}
+	if (&Terminfo{}).ACSValid() {
+		t.Errorf("expected undefined acsc to be invalid")
+	}
+}
+
+func TestEraseToEndOfDisplay(t *testing.T) {
+	ti, err := Load("vt100")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s, exp := string(ti.EraseToEndOfDisplay(5)), string(ti.Strings[ClrEos]); s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+	ti = &Terminfo{
+		Nums: map[int]int{Lines: 3},
+		Strings: map[int][]byte{
+			SaveCursor:    []byte("\x1b7"),
+			RestoreCursor: []byte("\x1b8"),
+			ClrEol:        []byte("\x1b[K"),
+			CursorDown:    []byte("\x1b[B"),
+		},
+	}
+	for _, test := range []struct {
+		row int
+		exp string
+	}{
+		{-1, "\x1b7\x1b[K\x1b[B\x1b[K\x1b[B\x1b[K\x1b8"},
+		{0, "\x1b7\x1b[K\x1b[B\x1b[K\x1b[B\x1b[K\x1b8"},
+		{1, "\x1b7\x1b[K\x1b[B\x1b[K\x1b8"},
+		{2, "\x1b7\x1b[K\x1b8"},
+		{3, "\x1b7\x1b[K\x1b8"},
+	} {
+		if s := string(ti.EraseToEndOfDisplay(test.row)); s != test.exp {
+			t.Errorf("row %d expected %q, got: %q", test.row, test.exp, s)
+		}
+	}
+	ti.Strings[CursorDown] = []byte("\n")
+	if s := ti.EraseToEndOfDisplay(0); s != nil {
+		t.Errorf("expected nil, got: %q", s)
+	}
+}
+
+func TestCacheDirListings(t *testing.T) {
+	defer SetCacheDirListings(false)
+	defer ResetDirListings()
+	SetCacheDirListings(true)
+	dir := t.TempDir()
+	t.Setenv("TERMINFO", dir)
+	if err := os.Mkdir(filepath.Join(dir, "l"), 0o755); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	write := func(name string) {
+		buf := buildTerminfo(name+"|listing test", nil, map[int][]byte{ClearScreen: []byte("\x1b[H\x1b[2J")})
+		if err := os.WriteFile(filepath.Join(dir, "l", name), buf, 0o644); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	write("listing-test-1")
+	ti, err := Load("listing-test-1")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := filepath.Join(dir, "l", "listing-test-1"); ti.File != exp {
+		t.Errorf("expected %q, got: %q", exp, ti.File)
+	}
+	// entries added after the directory was listed are not found
+	write("listing-test-2")
+	if _, err := Load("listing-test-2"); err == nil {
+		t.Errorf("expected error")
+	}
+	// until the listings are reset
+	ResetDirListings()
+	if _, err := Load("listing-test-2"); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+	// other directories are still checked
+	if _, err := Load("vt100"); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+}
+
+func TestColorModel(t *testing.T) {
+	setaf := []byte("\x1b[3%p1%dm")
+	tests := []struct {
+		ti  *Terminfo
+		exp ColorModel
+	}{
+		{&Terminfo{}, ColorModelNone},
+		{&Terminfo{Nums: map[int]int{MaxColors: -1}, Strings: map[int][]byte{SetAForeground: setaf}}, ColorModelNone},
+		{&Terminfo{Nums: map[int]int{MaxColors: 8}}, ColorModelNone},
+		{&Terminfo{Nums: map[int]int{MaxColors: 8}, Strings: map[int][]byte{SetForeground: []byte("\x1b[3%p1%dm")}}, ColorModelStandard},
+		{&Terminfo{Nums: map[int]int{MaxColors: 8}, Strings: map[int][]byte{SetAForeground: setaf}}, ColorModelStandard},
+		{&Terminfo{Nums: map[int]int{MaxColors: 16}, Strings: map[int][]byte{SetAForeground: setaf}}, ColorModelAixterm},
+		{&Terminfo{Nums: map[int]int{MaxColors: 88}, Strings: map[int][]byte{SetAForeground: setaf}}, ColorModel256},
+		{&Terminfo{Nums: map[int]int{MaxColors: 0x1000000}, Strings: map[int][]byte{SetAForeground: setaf}}, ColorModelDirect},
+		{&Terminfo{
+			Nums:         map[int]int{MaxColors: 256},
+			Strings:      map[int][]byte{SetAForeground: setaf},
+			ExtBools:     map[int]bool{0: true},
+			ExtBoolNames: map[int][]byte{0: []byte("RGB")},
+		}, ColorModelDirect},
+	}
+	for i, test := range tests {
+		if m := test.ti.ColorModel(); m != test.exp {
+			t.Errorf("test %d expected %s, got: %s", i, test.exp, m)
+		}
+	}
+	for term, exp := range map[string]ColorModel{"vt100": ColorModelNone, "xterm": ColorModelStandard, "xterm-256color": ColorModel256} {
+		ti, err := Load(term)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if m := ti.ColorModel(); m != exp {
+			t.Errorf("%s expected %s, got: %s", term, exp, m)
+		}
+	}
+}
+
+func TestUsesACS(t *testing.T) {
+	ti, err := Load("xterm-256color")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	// sgr contains both \E(0 and \E(B, and sgr0 contains \E(B
+	if z, exp := ti.UsesACS(), []int{ExitAttributeMode, SetAttributes}; !reflect.DeepEqual(z, exp) {
+		t.Errorf("expected %v, got: %v", exp, z)
+	}
+	if z := (&Terminfo{}).UsesACS(); z != nil {
+		t.Errorf("expected nil, got: %v", z)
+	}
+}
+
+func TestSameCaps(t *testing.T) {
+	ti, err := Load("xterm-256color")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	buf, err := os.ReadFile(ti.File)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	z, err := Decode(buf)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	z.Names, z.LongName = []string{"xterm-copy"}, "copy of xterm-256color"
+	if !SameCaps(ti, z) {
+		t.Errorf("expected same caps")
+	}
+	z.ExtStrings = map[int][]byte{}
+	if SameCaps(ti, z) {
+		t.Errorf("expected different extended caps")
+	}
+	vt102, err := Load("vt102")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	vt100, err := Load("vt100")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if SameCaps(vt100, vt102) {
+		t.Errorf("expected vt100 and vt102 to have different caps")
+	}
+}
+
+func TestOperandOrder(t *testing.T) {
+	// binary operators apply to the second from top and top of the stack, in
+	// that order
+	tests := []struct {
+		z      string
+		params []interface{}
+		exp    string
+	}{
+		{"%p1%{1}%+%d", []interface{}{5}, "6"},
+		{"%p1%p2%-%d", []interface{}{10, 3}, "7"},
+		{"%p2%p1%-%d", []interface{}{10, 3}, "-7"},
+		{"%p1%p2%/%d", []interface{}{10, 3}, "3"},
+		{"%p1%p2%m%d", []interface{}{10, 3}, "1"},
+		{"%p1%p2%*%d", []interface{}{10, 3}, "30"},
+		{"%?%p1%p2%>%t1%e0%;", []interface{}{10, 3}, "1"},
+		{"%?%p1%p2%<%t1%e0%;", []interface{}{10, 3}, "0"},
+		{"%p1%p2%/%d", []interface{}{10, 0}, "0"},
+		// cursor_address computed without %i, ie \E[%p1%{1}%+%d;%p2%{1}%+%dH
+		{"\x1b[%p1%{1}%+%d;%p2%{1}%+%dH", []interface{}{5, 10}, "\x1b[6;11H"},
+		// a termcap style offset, ie \E=%p1%' '%+%c (row + 32)
+		{"%p1%{32}%+%d", []interface{}{5}, "37"},
+	}
+	for i, test := range tests {
+		if s := Printf([]byte(test.z), test.params...); s != test.exp {
+			t.Errorf("test %d %q expected %q, got: %q", i, test.z, test.exp, s)
+		}
+		ti := &Terminfo{Strings: map[int][]byte{CursorAddress: []byte(test.z)}}
+		c, err := ti.Compile(CursorAddress)
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		if s := c.Exec(test.params...); s != test.exp {
+			t.Errorf("test %d %q compiled expected %q, got: %q", i, test.z, test.exp, s)
+		}
+	}
+}
+
+func TestLoadPrefix(t *testing.T) {
+	tis, err := LoadPrefix("xterm")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var names []string
+	for _, ti := range tis {
+		if !strings.HasPrefix(ti.Names[0], "xterm") {
+			t.Errorf("expected %q to start with xterm", ti.Names[0])
+		}
+		names = append(names, ti.Names[0])
+	}
+	if !slices.IsSorted(names) {
+		t.Errorf("expected names to be sorted, got: %v", names)
+	}
+	if len(slices.Compact(slices.Clone(names))) != len(names) {
+		t.Errorf("expected names to be unique, got: %v", names)
+	}
+	for _, exp := range []string{"xterm", "xterm-256color"} {
+		if !slices.Contains(names, exp) {
+			t.Errorf("expected %s, got: %v", exp, names)
+		}
+	}
+	if tis, err = LoadPrefix("not-a-term-prefix"); err != nil || len(tis) != 0 {
+		t.Errorf("expected no terms and no error, got: %v, %v", tis, err)
+	}
+	if _, err = LoadPrefix(""); err != ErrEmptyTermName {
+		t.Errorf("expected %v, got: %v", ErrEmptyTermName, err)
+	}
+	// an invalid file is skipped
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "x"), 0o755); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "x", "xterm-invalid"), []byte("not a terminfo file"), 0o644); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	t.Setenv("TERMINFO", dir)
+	z, err := LoadPrefix("xterm")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(z) != len(names) {
+		t.Errorf("expected %d terms, got: %d", len(names), len(z))
+	}
+}
+
+func TestUnderlineStyle(t *testing.T) {
+	ti := &Terminfo{
+		ExtStrings:     map[int][]byte{0: []byte("\x1b[4:%p1%dm")},
+		ExtStringNames: map[int][]byte{0: []byte("Smulx")},
+	}
+	for style, exp := range map[int]string{UnderlineNone: "\x1b[4:0m", UnderlineCurly: "\x1b[4:3m", UnderlineDashed: "\x1b[4:5m"} {
+		if s, ok := ti.UnderlineStyle(style); !ok || string(s) != exp {
+			t.Errorf("style %d expected %q, got: %q, %t", style, exp, s, ok)
+		}
+	}
+	if s, ok := ti.UnderlineStyle(6); ok {
+		t.Errorf("expected invalid style, got: %q", s)
+	}
+	if s, ok := (&Terminfo{}).UnderlineStyle(UnderlineSingle); ok {
+		t.Errorf("expected Smulx to not be defined, got: %q", s)
+	}
+}
+
+func TestDecodeNegativeCounts(t *testing.T) {
+	buf := buildTerminfo("neg|negative counts", map[int]int{Columns: 80}, map[int][]byte{ClearScreen: []byte("\x1b[H\x1b[2J")})
+	for _, field := range []int{fieldNameSize, fieldBoolCount, fieldNumCount, fieldStringCount, fieldTableSize} {
+		z := append([]byte{}, buf...)
+		// set the field to -2
+		z[2*field], z[2*field+1] = 0xfe, 0xff
+		_, err := Decode(z)
+		if herr, ok := err.(*HeaderError); !ok || herr.Field != headerFields[field] || herr.Value != -2 || !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("field %d expected %v for %s, got: %v", field, ErrInvalidHeader, headerFields[field], err)
+		}
+	}
+	// add an empty extended section
+	if len(buf)%2 != 0 {
+		buf = append(buf, 0)
+	}
+	base := len(buf)
+	buf = append(buf, make([]byte, 10)...)
+	if _, err := Decode(buf); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	for _, field := range []int{fieldExtBoolCount, fieldExtNumCount, fieldExtStringCount, fieldExtOffsetCount, fieldExtTableSize} {
+		z := append([]byte{}, buf...)
+		z[base+2*field], z[base+2*field+1] = 0xfe, 0xff
+		_, err := Decode(z)
+		if herr, ok := err.(*HeaderError); !ok || herr.Field != extHeaderFields[field] || herr.Value != -2 || !errors.Is(err, ErrInvalidExtendedHeader) {
+			t.Errorf("extended field %d expected %v for %s, got: %v", field, ErrInvalidExtendedHeader, extHeaderFields[field], err)
+		}
+	}
+	// corrupting any byte of a real file must not panic
+	ti, err := Load("xterm-256color")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if buf, err = os.ReadFile(ti.File); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	for i := range buf {
+		z := append([]byte{}, buf...)
+		z[i] = 0x80
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("byte %d: expected no panic, got: %v", i, r)
+				}
+			}()
+			_, _ = Decode(z)
+		}()
+	}
+}
+
+func TestFocusEvents(t *testing.T) {
+	ti := &Terminfo{
+		ExtStrings:     map[int][]byte{0: []byte("\x1b[?1004l"), 1: []byte("\x1b[?1004h"), 2: []byte("\x1b[I")},
+		ExtStringNames: map[int][]byte{0: []byte("fd"), 1: []byte("fe"), 2: []byte("kxIN")},
+	}
+	if s, ok := ti.ExtString("kxIN"); !ok || string(s) != "\x1b[I" {
+		t.Errorf("expected kxIN to be %q, got: %q, %t", "\x1b[I", s, ok)
+	}
+	if s, ok := ti.ExtString("kxOUT"); ok {
+		t.Errorf("expected kxOUT to not be defined, got: %q", s)
+	}
+	enable, disable, ok := ti.FocusEvents()
+	if !ok || string(enable) != "\x1b[?1004h" || string(disable) != "\x1b[?1004l" {
+		t.Errorf("unexpected focus event caps: %q, %q, %t", enable, disable, ok)
+	}
+	if _, _, ok := (&Terminfo{}).FocusEvents(); ok {
+		t.Errorf("expected focus event caps to not be defined")
+	}
+}
+
+func TestExtNum(t *testing.T) {
+	ti := &Terminfo{
+		ExtNums:     map[int]int{0: 1, 1: -1},
+		ExtNumNames: map[int][]byte{0: []byte("U8"), 1: []byte("RGB")},
+	}
+	if n, ok := ti.ExtNum("U8"); !ok || n != 1 {
+		t.Errorf("expected 1, true, got: %d, %t", n, ok)
+	}
+	for _, name := range []string{"RGB", "XT"} {
+		if n, ok := ti.ExtNum(name); ok {
+			t.Errorf("expected %s to not be present, got: %d", name, n)
+		}
+	}
+}
+
+func TestColumnRowAddress(t *testing.T) {
+	ti, err := Load("xterm-256color")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s, exp := string(ti.ColumnAddress(9)), "\x1b[10G"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+	if s, exp := string(ti.RowAddress(4)), "\x1b[5d"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+	if ti, err = Load("vt100"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s := ti.ColumnAddress(9); s != nil {
+		t.Errorf("expected nil, got: %q", s)
+	}
+	if s := ti.RowAddress(4); s != nil {
+		t.Errorf("expected nil, got: %q", s)
+	}
+}
+
+func TestBackspaceWraps(t *testing.T) {
+	if !(&Terminfo{Bools: map[int]bool{AutoLeftMargin: true}}).BackspaceWraps() {
+		t.Errorf("expected bw to be set")
+	}
+	ti, err := Load("xterm-256color")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if ti.BackspaceWraps() {
+		t.Errorf("expected xterm-256color to not set bw")
+	}
+}
+
+func TestTicCheck(t *testing.T) {
+	if _, err := exec.LookPath("tic"); err != nil {
+		if _, err := (&Terminfo{Names: []string{"foo"}}).TicCheck(); err != ErrTicNotFound {
+			t.Errorf("expected %v, got: %v", ErrTicNotFound, err)
+		}
+		t.Skip("tic not installed")
+	}
+	ti := &Terminfo{
+		Names: []string{"foo"},
+		Nums:  map[int]int{Columns: 80},
+		Strings: map[int][]byte{
+			CursorAddress:  []byte("\x1b[%i%p1%d;%p2%dH"),
+			SetAForeground: []byte("\x1b[3%p1%d%p2%dm"),
+			SetABackground: []byte("\x1b[4%p1%dm"),
+		},
+	}
+	diags, err := ti.TicCheck()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(diags) != 1 || !strings.Contains(diags[0], "setaf uses 2 parameters") {
+		t.Errorf("expected setaf diagnostic, got: %q", diags)
+	}
+	ti.Strings[SetAForeground] = []byte("\x1b[3%p1%dm")
+	if diags, err = ti.TicCheck(); err != nil || len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got: %q, %v", diags, err)
+	}
+}
+
+func TestSetColorPair(t *testing.T) {
+	ti := &Terminfo{
+		Nums:    map[int]int{MaxPairs: 64},
+		Strings: map[int][]byte{SetColorPair: []byte("\x1b[%p1%dP")},
+	}
+	if z, ok := ti.SetColorPair(7); !ok || string(z) != "\x1b[7P" {
+		t.Errorf("expected %q, got: %q, %t", "\x1b[7P", z, ok)
+	}
+	for _, pair := range []int{-1, 64} {
+		if z, ok := ti.SetColorPair(pair); ok || z != nil {
+			t.Errorf("expected pair %d to be invalid, got: %q, %t", pair, z, ok)
+		}
+	}
+	if ti, err := Load("xterm-256color"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	} else if z, ok := ti.SetColorPair(1); ok || z != nil {
+		t.Errorf("expected no scp, got: %q, %t", z, ok)
+	}
+}
+
+func TestInitStrings(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "init")
+	if err := os.WriteFile(file, []byte("\x1b[?7h"), 0o644); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	ti := &Terminfo{
+		Strings: map[int][]byte{
+			Init3string: []byte("is3"),
+			InitFile:    []byte(file),
+			Init2string: []byte("is2"),
+			Init1string: []byte("is1"),
+			InitProg:    []byte("/usr/bin/tput"),
+		},
+	}
+	z := ti.InitStrings()
+	if exp := [][]byte{[]byte("/usr/bin/tput"), []byte("is1"), []byte("is2"), []byte("\x1b[?7h"), []byte("is3")}; !reflect.DeepEqual(z, exp) {
+		t.Errorf("expected %q, got: %q", exp, z)
+	}
+	// an init file that cannot be read is omitted
+	ti.Strings[InitFile] = []byte(filepath.Join(t.TempDir(), "missing"))
+	delete(ti.Strings, InitProg)
+	z = ti.InitStrings()
+	if exp := [][]byte{[]byte("is1"), []byte("is2"), []byte("is3")}; !reflect.DeepEqual(z, exp) {
+		t.Errorf("expected %q, got: %q", exp, z)
+	}
+}
+
+func TestClearLine(t *testing.T) {
+	full := &Terminfo{Strings: map[int][]byte{
+		ClrEol: []byte("\x1b[K"),
+		ClrBol: []byte("\x1b[1K"),
+		ClrEos: []byte("\x1b[J"),
+	}}
+	noEl1 := &Terminfo{Strings: map[int][]byte{
+		ClrEol: []byte("\x1b[K"),
+		ClrEos: []byte("\x1b[J"),
+	}}
+	noEl := &Terminfo{Strings: map[int][]byte{
+		ClrEos: []byte("\x1b[J"),
+	}}
+	tests := []struct {
+		ti   *Terminfo
+		mode int
+		exp  []byte
+	}{
+		{full, ClearModeEndOfLine, []byte("\x1b[K")},
+		{full, ClearModeBeginningOfLine, []byte("\x1b[1K")},
+		{full, ClearModeEndOfScreen, []byte("\x1b[J")},
+		{full, -1, nil},
+		{full, ClearModeEndOfScreen + 1, nil},
+		{noEl1, ClearModeEndOfLine, []byte("\x1b[K")},
+		{noEl1, ClearModeBeginningOfLine, nil},
+		{noEl1, ClearModeEndOfScreen, []byte("\x1b[J")},
+		{noEl, ClearModeEndOfLine, nil},
+		{noEl, ClearModeBeginningOfLine, nil},
+		{noEl, ClearModeEndOfScreen, []byte("\x1b[J")},
+	}
+	for i, test := range tests {
+		if z := test.ti.ClearLine(test.mode); !bytes.Equal(z, test.exp) || (z == nil) != (test.exp == nil) {
+			t.Errorf("test %d expected %q, got: %q", i, test.exp, z)
+		}
+	}
+	for i, test := range []struct {
+		ti  *Terminfo
+		exp []byte
+	}{
+		{full, []byte("\x1b[K")},
+		{noEl1, []byte("\x1b[K")},
+		{noEl, nil},
+	} {
+		if z := test.ti.ClearToEnd(); !bytes.Equal(z, test.exp) || (z == nil) != (test.exp == nil) {
+			t.Errorf("test %d expected %q, got: %q", i, test.exp, z)
+		}
+	}
+}
+
+func TestPrintCaps(t *testing.T) {
+	ti, err := Load("vt100")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	exp := PrintCaps{
+		PrintScreen: []byte("\x1b[0i"),
+		PrtrOn:      []byte("\x1b[5i"),
+		PrtrOff:     []byte("\x1b[4i"),
+		PrtrSilent:  true,
+	}
+	if caps := ti.PrintCaps(); !reflect.DeepEqual(caps, exp) {
+		t.Errorf("expected %+v, got: %+v", exp, caps)
+	}
+	if ti, err = Load("dumb"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if caps := ti.PrintCaps(); !reflect.DeepEqual(caps, PrintCaps{}) {
+		t.Errorf("expected no print caps, got: %+v", caps)
+	}
+}
+
+func TestBackColorErase(t *testing.T) {
+	for _, test := range []struct {
+		term string
+		exp  bool
+	}{
+		{"xterm-256color", true},
+		{"vt100", false},
+	} {
+		ti, err := Load(test.term)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if b := ti.BackColorErase(); b != test.exp {
+			t.Errorf("%s expected %t, got: %t", test.term, test.exp, b)
+		}
+	}
+}
+
+func TestCursorPositionReport(t *testing.T) {
+	ti, err := Load("xterm-256color")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	query, format, ok := ti.CursorPositionReport()
+	if !ok || string(query) != "\x1b[6n" || string(format) != "\x1b[%i%d;%dR" {
+		t.Errorf("unexpected cursor position report caps: %q, %q, %t", query, format, ok)
+	}
+	for _, ti := range []*Terminfo{
+		{Strings: map[int][]byte{}},
+		{Strings: map[int][]byte{User7: []byte("\x1b[6n")}},
+		{Strings: map[int][]byte{User6: []byte("\x1b[%i%d;%dR")}},
+	} {
+		if query, format, ok := ti.CursorPositionReport(); ok || query != nil || format != nil {
+			t.Errorf("expected no cursor position report caps, got: %q, %q, %t", query, format, ok)
+		}
+	}
+}
+
+func TestExtCapName(t *testing.T) {
+	ti := &Terminfo{
+		ExtBoolNames:   map[int][]byte{0: []byte("AX"), 1: []byte("XT")},
+		ExtNumNames:    map[int][]byte{0: []byte("RGB")},
+		ExtStringNames: map[int][]byte{0: []byte("Ms"), 1: []byte("Se"), 2: []byte("Ss")},
+	}
+	for _, test := range []struct {
+		name  func(int) string
+		names map[int][]byte
+	}{
+		{ti.ExtBoolName, ti.ExtBoolNames},
+		{ti.ExtNumName, ti.ExtNumNames},
+		{ti.ExtStringName, ti.ExtStringNames},
+	} {
+		for i, v := range test.names {
+			if s := test.name(i); s != string(v) {
+				t.Errorf("expected %q, got: %q", v, s)
+			}
+		}
+		for _, i := range []int{-1, len(test.names), 1 << 20} {
+			if s := test.name(i); s != "" {
+				t.Errorf("expected index %d to have no name, got: %q", i, s)
+			}
+		}
+	}
+}